@@ -0,0 +1,351 @@
+package bobra
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 校验命令位置参数的函数
+type PositionalArgs func(cmd *Command, args []string) error
+
+// 不接受任何位置参数
+func NoArgs(cmd *Command, args []string) error {
+	if len(args) > 0 {
+		return fmt.Errorf("command '%s' accepts no arguments, received %d", cmd.CommandPath(), len(args))
+	}
+	return nil
+}
+
+// 接受任意数量的位置参数
+func ArbitraryArgs(cmd *Command, args []string) error {
+	return nil
+}
+
+// 只接受 n 个位置参数
+func ExactArgs(n int) PositionalArgs {
+	return func(cmd *Command, args []string) error {
+		if len(args) != n {
+			return fmt.Errorf("command '%s' accepts %d arg(s), received %d", cmd.CommandPath(), n, len(args))
+		}
+		return nil
+	}
+}
+
+// 至少接受 n 个位置参数
+func MinimumNArgs(n int) PositionalArgs {
+	return func(cmd *Command, args []string) error {
+		if len(args) < n {
+			return fmt.Errorf("command '%s' requires at least %d arg(s), received %d", cmd.CommandPath(), n, len(args))
+		}
+		return nil
+	}
+}
+
+// 至多接受 n 个位置参数
+func MaximumNArgs(n int) PositionalArgs {
+	return func(cmd *Command, args []string) error {
+		if len(args) > n {
+			return fmt.Errorf("command '%s' accepts at most %d arg(s), received %d", cmd.CommandPath(), n, len(args))
+		}
+		return nil
+	}
+}
+
+// 接受 min 到 max 个位置参数
+func RangeArgs(min int, max int) PositionalArgs {
+	return func(cmd *Command, args []string) error {
+		if len(args) < min || len(args) > max {
+			return fmt.Errorf("command '%s' accepts between %d and %d arg(s), received %d", cmd.CommandPath(), min, max, len(args))
+		}
+		return nil
+	}
+}
+
+// 只接受 ValidArgs 中列出的位置参数。没有设置 ValidArgs 时，每个位置参数需要在 ValidArgsFunction 根据它之前的位置参数返回的候选值中
+func OnlyValidArgs(cmd *Command, args []string) error {
+	for i, arg := range args {
+		valid := cmd.ValidArgs
+		if valid == nil && cmd.ValidArgsFunction != nil {
+			candidates, directive := cmd.ValidArgsFunction(cmd, args[:i], "")
+			if directive&ShellCompDirectiveError != 0 {
+				return fmt.Errorf("failed to list valid arguments for command '%s'", cmd.CommandPath())
+			}
+			valid = candidates
+		}
+		if !stringInSlice(arg, valid) {
+			return fmt.Errorf("invalid argument '%s' for command '%s', valid arguments are: %s", arg, cmd.CommandPath(), strings.Join(valid, ", "))
+		}
+	}
+	return nil
+}
+
+// 依次执行全部校验函数，返回第一个错误
+func MatchAll(validators ...PositionalArgs) PositionalArgs {
+	return func(cmd *Command, args []string) error {
+		for _, validate := range validators {
+			if err := validate(cmd, args); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// Use 中声明的一个位置参数
+type useArg struct {
+	// 参数的名称
+	name string
+	// 在 Use 中的写法，如 <src>、[name]、<file>...
+	placeholder string
+	// 以 [name] 形式声明的参数可以省略
+	optional bool
+	// 以 ... 结尾的参数可以重复出现
+	variadic bool
+}
+
+// 这些占位符表示 flags 或子命令，而不是位置参数
+var nonArgPlaceholders = []string{"flags", "options", "command"}
+
+// 解析 Use 中声明的位置参数：<name> 为必填参数，[name] 为可选参数，以 ... 结尾表示可以重复出现
+func (c *Command) useArgs() []useArg {
+	args := []useArg{}
+	fields := strings.Fields(c.Use)
+	if len(fields) == 0 {
+		return args
+	}
+	for _, field := range fields[1:] {
+		arg := useArg{placeholder: field}
+		name := field
+		if strings.HasSuffix(name, "...") {
+			arg.variadic = true
+			name = strings.TrimSuffix(name, "...")
+		}
+		switch {
+		case strings.HasPrefix(name, "<") && strings.HasSuffix(name, ">"):
+		case strings.HasPrefix(name, "[") && strings.HasSuffix(name, "]"):
+			arg.optional = true
+		default:
+			continue
+		}
+		arg.name = name[1 : len(name)-1]
+		if arg.optional && stringInSlice(arg.name, nonArgPlaceholders) {
+			continue
+		}
+		args = append(args, arg)
+	}
+	return args
+}
+
+// 返回 Use 中声明的位置参数的名称，如 "copy <src> [dst]" 返回 [src dst]
+func (c *Command) ArgNames() []string {
+	names := []string{}
+	for _, arg := range c.useArgs() {
+		names = append(names, arg.name)
+	}
+	return names
+}
+
+// 返回 Use 中声明的位置参数的写法，用于使用方法的 Arguments 部分
+func (c *Command) ArgPlaceholders() []string {
+	placeholders := []string{}
+	for _, arg := range c.useArgs() {
+		placeholders = append(placeholders, arg.placeholder)
+	}
+	return placeholders
+}
+
+// 根据 Use 中声明的位置参数计算位置参数的最少和最多数量，max 为 -1 表示没有上限
+func (c *Command) useArity() (min int, max int) {
+	for _, arg := range c.useArgs() {
+		if !arg.optional {
+			min++
+		}
+		if arg.variadic || max < 0 {
+			max = -1
+		} else {
+			max++
+		}
+	}
+	return min, max
+}
+
+// 返回 Use 中声明的名为 name 的位置参数在本次执行中的取值，没有声明或没有指定时返回空字符串
+func (c *Command) Arg(name string) string {
+	args := c.positionalArgs()
+	for i, n := range c.ArgNames() {
+		if n == name && i < len(args) {
+			return args[i]
+		}
+	}
+	return ""
+}
+
+// 返回 Use 中声明的位置参数名称到本次执行中取值的映射，没有指定的参数不在其中。
+// 可以重复出现的参数对应其全部取值以空格拼接的结果，如 "cat <file>..." 中 file 为 "a.txt b.txt"
+func (c *Command) NamedArgs() map[string]string {
+	named := map[string]string{}
+	args := c.positionalArgs()
+	declared := c.useArgs()
+	for i, arg := range declared {
+		if i >= len(args) {
+			break
+		}
+		if arg.variadic {
+			// 可以重复出现的参数之后仍有声明的参数时，为它们留出取值
+			end := len(args) - (len(declared) - i - 1)
+			if end <= i {
+				end = i + 1
+			}
+			named[arg.name] = strings.Join(args[i:end], " ")
+			args = append(args[:i+1:i+1], args[end:]...)
+			continue
+		}
+		named[arg.name] = args[i]
+	}
+	return named
+}
+
+// 返回校验位置参数的函数，没有设置 Args 时根据 Use 中声明的位置参数校验数量的范围
+func (c *Command) argsValidator() PositionalArgs {
+	if c.Args != nil {
+		return c.Args
+	}
+	if len(c.useArgs()) == 0 {
+		return nil
+	}
+	switch min, max := c.useArity(); {
+	case max < 0:
+		return MinimumNArgs(min)
+	case min == max:
+		return ExactArgs(min)
+	default:
+		return RangeArgs(min, max)
+	}
+}
+
+// 将位置参数转换后保存到变量中的绑定
+type argBinding struct {
+	index int
+	kind  string
+	set   func(value string) error
+}
+
+// 将第 index 个位置参数转换为整数并在 Run 之前保存到 p 中，没有指定该参数时 p 保持不变
+func (c *Command) BindArgInt(index int, p *int) {
+	c.argBindings = append(c.argBindings, argBinding{index: index, kind: "an integer", set: func(value string) error {
+		v, err := strconv.Atoi(value)
+		if err == nil {
+			*p = v
+		}
+		return err
+	}})
+}
+
+// 将第 index 个位置参数转换为时间间隔并在 Run 之前保存到 p 中，没有指定该参数时 p 保持不变
+func (c *Command) BindArgDuration(index int, p *time.Duration) {
+	c.argBindings = append(c.argBindings, argBinding{index: index, kind: "a duration such as 30s or 5m", set: func(value string) error {
+		v, err := time.ParseDuration(value)
+		if err == nil {
+			*p = v
+		}
+		return err
+	}})
+}
+
+// 转换绑定的位置参数并保存到对应的变量中
+func (c *Command) applyArgBindings(args []string) error {
+	names := c.ArgNames()
+	for _, b := range c.argBindings {
+		if b.index >= len(args) {
+			continue
+		}
+		if err := b.set(args[b.index]); err != nil {
+			name := strconv.Itoa(b.index + 1)
+			if b.index < len(names) {
+				name = "<" + names[b.index] + ">"
+			}
+			return fmt.Errorf("invalid value '%s' for argument %s: must be %s", args[b.index], name, b.kind)
+		}
+	}
+	return nil
+}
+
+// 判断输入是否为终端，测试时可以替换
+var isInteractive = func(r io.Reader) bool {
+	f, ok := r.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// 输入来自终端时，依次提示用户输入 Use 中声明了但没有指定的必填位置参数
+func (c *Command) promptMissingArgs() {
+	in := c.InOrStdin()
+	if !isInteractive(in) {
+		return
+	}
+	reader := bufio.NewReader(in)
+	names := c.ArgNames()
+	min, _ := c.useArity()
+	for i := len(c.positionalArgs()); i < min; i++ {
+		fmt.Printf("Enter value for <%s>: ", names[i])
+		line, err := reader.ReadString('\n')
+		value := strings.TrimRight(line, "\r\n")
+		if err != nil && value == "" {
+			return
+		}
+		c.promptedArgs = append(c.promptedArgs, value)
+	}
+}
+
+// 位置参数中有 "-" 时读取输入的全部内容，用于替换这些参数
+func (c *Command) readStdinArg() error {
+	if !stringInSlice("-", c.positionalArgs()) {
+		return nil
+	}
+	content, err := ioutil.ReadAll(c.InOrStdin())
+	if err != nil {
+		return fmt.Errorf("failed to read argument from stdin: %v", err)
+	}
+	arg := strings.TrimSuffix(strings.TrimSuffix(string(content), "\n"), "\r")
+	c.stdinArg = &arg
+	return nil
+}
+
+// 将 args 中 @file 形式的参数替换为文件中的参数，文件中的参数按照 shell 的规则切分，以 # 开头的行为注释。
+// 第一个参数为程序名称，"--" 之后的参数原样保留
+func expandResponseFiles(args []string) ([]string, error) {
+	expanded := []string{}
+	for i, arg := range args {
+		if arg == "--" {
+			return append(expanded, args[i:]...), nil
+		}
+		if i == 0 || !strings.HasPrefix(arg, "@") || len(arg) == 1 {
+			expanded = append(expanded, arg)
+			continue
+		}
+		content, err := ioutil.ReadFile(arg[1:])
+		if err != nil {
+			return nil, fmt.Errorf("failed to read response file: %v", err)
+		}
+		for _, line := range strings.Split(string(content), "\n") {
+			if strings.HasPrefix(strings.TrimSpace(line), "#") {
+				continue
+			}
+			fileArgs, err := splitArgs(line)
+			if err != nil {
+				return nil, fmt.Errorf("invalid response file '%s': %v", arg[1:], err)
+			}
+			expanded = append(expanded, fileArgs...)
+		}
+	}
+	return expanded, nil
+}
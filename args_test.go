@@ -0,0 +1,298 @@
+package bobra
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// 测试内置的位置参数校验函数
+func TestPositionalArgs(t *testing.T) {
+	c := &Command{Use: "copy"}
+	tests := []struct {
+		validator PositionalArgs
+		args      []string
+		err       string
+	}{
+		{NoArgs, []string{}, ""},
+		{NoArgs, []string{"a"}, "command 'copy' accepts no arguments, received 1"},
+		{ArbitraryArgs, []string{"a", "b", "c"}, ""},
+		{ExactArgs(2), []string{"a", "b"}, ""},
+		{ExactArgs(2), []string{"a"}, "command 'copy' accepts 2 arg(s), received 1"},
+		{MinimumNArgs(1), []string{"a", "b"}, ""},
+		{MinimumNArgs(1), []string{}, "command 'copy' requires at least 1 arg(s), received 0"},
+		{MaximumNArgs(1), []string{}, ""},
+		{MaximumNArgs(1), []string{"a", "b"}, "command 'copy' accepts at most 1 arg(s), received 2"},
+		{RangeArgs(1, 2), []string{"a"}, ""},
+		{RangeArgs(1, 2), []string{"a", "b", "c"}, "command 'copy' accepts between 1 and 2 arg(s), received 3"},
+	}
+	for i, test := range tests {
+		err := test.validator(c, test.args)
+		if test.err == "" && err != nil {
+			t.Errorf("case %d: expected no error but got '%v'", i, err)
+		}
+		if test.err != "" && (err == nil || err.Error() != test.err) {
+			t.Errorf("case %d: expected '%s' but got '%v'", i, test.err, err)
+		}
+	}
+}
+
+// 测试 Args 在 Run 之前校验去除 flags 后的位置参数
+func TestCommand_Args(t *testing.T) {
+	ran := false
+	r := &Command{Use: "root"}
+	sub := &Command{
+		Use:          "copy",
+		Args:         ExactArgs(2),
+		SilenceUsage: true,
+		Run:          func(cmd *Command, args []string) { ran = true },
+	}
+	sub.LocalFlags().Bool("force", false, "overwrite")
+	r.AddCommand(sub)
+
+	if err := r.ExecuteLine("copy --force a.txt"); err == nil || !strings.Contains(err.Error(), "accepts 2 arg(s)") || ran {
+		t.Errorf("expected argument count error without running, but got '%v'", err)
+	}
+	if err := r.ExecuteLine("copy --force a.txt b.txt"); err != nil || !ran {
+		t.Errorf("expected command to run without error, but got '%v'", err)
+	}
+}
+
+// 测试 OnlyValidArgs 拒绝不在 ValidArgs 中的位置参数，并在错误中列出允许的取值
+func TestOnlyValidArgs(t *testing.T) {
+	c := &Command{Use: "service", ValidArgs: []string{"start", "stop", "status"}}
+	if err := OnlyValidArgs(c, []string{"start", "status"}); err != nil {
+		t.Errorf("expected no error but got '%v'", err)
+	}
+	expected := "invalid argument 'restart' for command 'service', valid arguments are: start, stop, status"
+	if err := OnlyValidArgs(c, []string{"restart"}); err == nil || err.Error() != expected {
+		t.Errorf("expected '%s' but got '%v'", expected, err)
+	}
+}
+
+// 测试 Use 中声明的位置参数可以按名称获取，数量不符时校验失败，并显示在使用方法的 Arguments 部分
+func TestCommand_ArgNames(t *testing.T) {
+	var src, dst string
+	var named map[string]string
+	r := &Command{Use: "root"}
+	sub := &Command{
+		Use:          "copy <src> <dst>",
+		SilenceUsage: true,
+		Run: func(cmd *Command, args []string) {
+			src, dst = cmd.Arg("src"), cmd.Arg("dst")
+			named = cmd.NamedArgs()
+		},
+	}
+	r.AddCommand(sub)
+
+	if err := r.ExecuteLine("copy a.txt"); err == nil {
+		t.Errorf("expected argument count error but got nil")
+	}
+	if err := r.ExecuteLine("copy a.txt b.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if src != "a.txt" || dst != "b.txt" || sub.Arg("missing") != "" {
+		t.Errorf("expected 'a.txt', 'b.txt' but got '%s', '%s'", src, dst)
+	}
+	if !reflect.DeepEqual(named, map[string]string{"src": "a.txt", "dst": "b.txt"}) {
+		t.Errorf("expected named arguments src=a.txt, dst=b.txt but got %v", named)
+	}
+
+	pack := &Command{Use: "pack <archive> <file>...", Run: func(cmd *Command, args []string) { named = cmd.NamedArgs() }}
+	r.AddCommand(pack)
+	if err := r.ExecuteLine("pack out.tar a.txt b.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(named, map[string]string{"archive": "out.tar", "file": "a.txt b.txt"}) {
+		t.Errorf("expected named arguments archive=out.tar, file='a.txt b.txt' but got %v", named)
+	}
+
+	var buf bytes.Buffer
+	sub.renderUsage(&buf)
+	if !strings.Contains(buf.String(), "Arguments:\n  <src>\n  <dst>\n") {
+		t.Errorf("expected Arguments section, but got:\n%s", buf.String())
+	}
+}
+
+// 测试绑定的位置参数在 Run 之前被转换，转换失败时返回易读的错误
+func TestCommand_BindArg(t *testing.T) {
+	var port int
+	var timeout time.Duration
+	r := &Command{Use: "root"}
+	sub := &Command{
+		Use:          "serve <port> <timeout>",
+		SilenceUsage: true,
+		Run:          func(cmd *Command, args []string) {},
+	}
+	sub.BindArgInt(0, &port)
+	sub.BindArgDuration(1, &timeout)
+	r.AddCommand(sub)
+
+	if err := r.ExecuteLine("serve 8080 30s"); err != nil {
+		t.Fatal(err)
+	}
+	if port != 8080 || timeout != 30*time.Second {
+		t.Errorf("expected 8080, 30s but got %d, %v", port, timeout)
+	}
+
+	expected := "invalid value 'soon' for argument <timeout>: must be a duration such as 30s or 5m"
+	if err := r.ExecuteLine("serve 8080 soon"); err == nil || err.Error() != expected {
+		t.Errorf("expected '%s' but got '%v'", expected, err)
+	}
+}
+
+// 测试 MatchAll 组合多个校验函数，返回第一个错误
+func TestMatchAll(t *testing.T) {
+	c := &Command{Use: "service", ValidArgs: []string{"start", "stop"}}
+	validate := MatchAll(ExactArgs(1), OnlyValidArgs)
+	if err := validate(c, []string{"start"}); err != nil {
+		t.Errorf("expected no error but got '%v'", err)
+	}
+	if err := validate(c, []string{"start", "stop"}); err == nil || !strings.Contains(err.Error(), "accepts 1 arg(s)") {
+		t.Errorf("expected argument count error but got '%v'", err)
+	}
+	if err := validate(c, []string{"restart"}); err == nil || !strings.Contains(err.Error(), "invalid argument 'restart'") {
+		t.Errorf("expected invalid argument error but got '%v'", err)
+	}
+}
+
+// 测试设置 PromptMissingArgs 且输入来自终端时，提示用户输入缺少的位置参数
+func TestCommand_PromptMissingArgs(t *testing.T) {
+	defer func(f func(io.Reader) bool) { isInteractive = f }(isInteractive)
+	isInteractive = func(r io.Reader) bool { return true }
+
+	var got []string
+	r := &Command{Use: "root"}
+	sub := &Command{
+		Use:               "copy <src> <dst>",
+		PromptMissingArgs: true,
+		Run: func(cmd *Command, args []string) {
+			got = []string{cmd.Arg("src"), cmd.Arg("dst")}
+		},
+	}
+	sub.SetIn(strings.NewReader("b.txt\n"))
+	r.AddCommand(sub)
+
+	out := captureStdout(t, func() {
+		if err := r.ExecuteLine("copy a.txt"); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if out != "Enter value for <dst>: " {
+		t.Errorf("expected prompt for <dst> but got '%s'", out)
+	}
+	expected := []string{"a.txt", "b.txt"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected '%q' but got '%q'", expected, got)
+	}
+}
+
+// 测试根据 Use 中声明的可选参数和可重复参数推导位置参数的数量范围
+func TestCommand_UseArity(t *testing.T) {
+	tests := []struct {
+		use   string
+		args  []string
+		valid bool
+	}{
+		{"rm <file>...", []string{}, false},
+		{"rm <file>...", []string{"a", "b", "c"}, true},
+		{"get [name]", []string{}, true},
+		{"get [name]", []string{"a", "b"}, false},
+		{"cp <src> [dst]", []string{"a"}, true},
+		{"cp <src> [dst]", []string{"a", "b", "c"}, false},
+		{"cat [file]...", []string{"a", "b"}, true},
+		{"run [flags]", []string{"a"}, true},
+	}
+	for _, test := range tests {
+		c := &Command{Use: test.use}
+		validate := c.argsValidator()
+		valid := validate == nil || validate(c, test.args) == nil
+		if valid != test.valid {
+			t.Errorf("expected '%s' with %q to be valid: %v", test.use, test.args, test.valid)
+		}
+	}
+
+	var buf bytes.Buffer
+	c := &Command{Use: "cp <src> [dst]...", Run: func(cmd *Command, args []string) {}}
+	c.renderUsage(&buf)
+	if !strings.Contains(buf.String(), "Arguments:\n  <src>\n  [dst]...\n") {
+		t.Errorf("expected Arguments section, but got:\n%s", buf.String())
+	}
+}
+
+// 测试设置 ReadStdinArgs 后值为 "-" 的位置参数被替换为输入的内容
+func TestCommand_ReadStdinArgs(t *testing.T) {
+	var got []string
+	r := &Command{Use: "root"}
+	sub := &Command{
+		Use:           "login <user> <token>",
+		ReadStdinArgs: true,
+		Run:           func(cmd *Command, args []string) { got = args },
+	}
+	sub.SetIn(strings.NewReader("s3cr3t\n"))
+	r.AddCommand(sub)
+
+	if err := r.ExecuteLine("login bob -"); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"bob", "s3cr3t"}
+	if !reflect.DeepEqual(got, expected) || sub.Arg("token") != "s3cr3t" {
+		t.Errorf("expected '%q' but got '%q'", expected, got)
+	}
+
+	sub.ReadStdinArgs = false
+	if err := r.ExecuteLine("login bob -"); err != nil {
+		t.Fatal(err)
+	}
+	expected = []string{"bob", "-"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected '%q' but got '%q'", expected, got)
+	}
+}
+
+// 测试设置 ExpandResponseFiles 后 @file 形式的参数被替换为文件中的参数
+func TestCommand_ExpandResponseFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bobra")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "args.txt")
+	content := "# build options\n--target linux\n'out dir/app' main.go\n"
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	var target string
+	r := &Command{Use: "root", ExpandResponseFiles: true}
+	sub := &Command{
+		Use: "build",
+		Run: func(cmd *Command, args []string) {
+			got = args
+			target, _ = cmd.Flags().GetString("target")
+		},
+	}
+	sub.LocalFlags().String("target", "", "target platform")
+	r.AddCommand(sub)
+
+	r.SetArgs([]string{"build", "@" + path, "--", "@literal"})
+	if err := r.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"out dir/app", "main.go", "@literal"}
+	if !reflect.DeepEqual(got, expected) || target != "linux" {
+		t.Errorf("expected '%q', 'linux' but got '%q', '%s'", expected, got, target)
+	}
+
+	r.SetArgs([]string{"build", "@" + filepath.Join(dir, "missing.txt")})
+	if err := r.Execute(); err == nil {
+		t.Errorf("expected missing response file error but got nil")
+	}
+}
@@ -0,0 +1,56 @@
+package bobra
+
+import (
+	flag "github.com/spf13/pflag"
+)
+
+// 命令树检查中发现的一个问题
+type AuditFinding struct {
+	// 存在问题的命令路径
+	Command string
+	// 存在问题的 flag 名称，问题与 flag 无关时为空
+	Flag string
+	// 问题描述
+	Issue string
+}
+
+// 检查问题的描述
+const (
+	IssueMissingShort        = "command has no short description"
+	IssueRunWithSubcommands  = "command has both Run and subcommands"
+	IssueRequiredFlagNoUsage = "required flag has no usage text"
+	IssueFlagNoDescription   = "flag has no description"
+)
+
+// 遍历以 c 为根的命令树，返回其中命令和 flags 定义上的问题，可以在测试中作为命令行程序的质量检查
+func (c *Command) AuditCommands() []AuditFinding {
+	findings := []AuditFinding{}
+	path := c.CommandPath()
+	if c.Short == "" {
+		findings = append(findings, AuditFinding{Command: path, Issue: IssueMissingShort})
+	}
+	if c.Runnable() && c.HasSubCommands() {
+		findings = append(findings, AuditFinding{Command: path, Issue: IssueRunWithSubcommands})
+	}
+
+	inherited := c.InheritedFlags()
+	c.Flags().VisitAll(func(f *flag.Flag) {
+		// 继承自祖先命令的 flags 只在定义它的命令中检查一次
+		if inherited.Lookup(f.Name) == f {
+			return
+		}
+		if f.Usage != "" {
+			return
+		}
+		issue := IssueFlagNoDescription
+		if isFlagRequired(f) {
+			issue = IssueRequiredFlagNoUsage
+		}
+		findings = append(findings, AuditFinding{Command: path, Flag: f.Name, Issue: issue})
+	})
+
+	for _, sub := range c.commands {
+		findings = append(findings, sub.AuditCommands()...)
+	}
+	return findings
+}
@@ -0,0 +1,31 @@
+package bobra
+
+import (
+	"reflect"
+	"testing"
+)
+
+// 测试检查能够发现有问题的命令树中的全部问题
+func TestCommand_AuditCommands(t *testing.T) {
+	r := &Command{Use: "root", Short: "root command"}
+	r.GlobalFlags().Bool("quiet", false, "")
+	service := &Command{Use: "service", Run: func(cmd *Command, args []string) {}}
+	start := &Command{Use: "start", Short: "start a service", Run: func(cmd *Command, args []string) {}}
+	start.LocalFlags().String("name", "", "")
+	start.LocalFlags().SetAnnotation("name", FlagRequiredAnnotation, []string{"true"})
+	start.LocalFlags().Int("port", 80, "")
+	start.LocalFlags().Bool("wait", false, "wait until started")
+	r.AddCommand(service)
+	service.AddCommand(start)
+
+	expected := []AuditFinding{
+		{Command: "root", Flag: "quiet", Issue: IssueFlagNoDescription},
+		{Command: "root service", Issue: IssueMissingShort},
+		{Command: "root service", Issue: IssueRunWithSubcommands},
+		{Command: "root service start", Flag: "name", Issue: IssueRequiredFlagNoUsage},
+		{Command: "root service start", Flag: "port", Issue: IssueFlagNoDescription},
+	}
+	if r := r.AuditCommands(); !reflect.DeepEqual(r, expected) {
+		t.Errorf("expected '%+v' but got '%+v'", expected, r)
+	}
+}
@@ -0,0 +1,96 @@
+package bobra
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 根据 opts 指向的结构体的字段标签为命令注册 flags 和位置参数绑定，解析后字段的值会在 Run 之前被填充。
+// 字段标签 flag:"name,shorthand,usage" 注册一个局部 flag，字段原来的值为其默认值，required:"true" 将其标记为必填；
+// 字段标签 arg:"0" 绑定第 1 个位置参数。支持 string、bool、int、float64、time.Duration 和 []string 类型的字段，
+// 带有标签的字段必须是导出的
+func (c *Command) Bind(opts interface{}) error {
+	v := reflect.ValueOf(opts)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("Bind requires a pointer to a struct, got %T", opts)
+	}
+	v = v.Elem()
+	for i := 0; i < v.NumField(); i++ {
+		field, value := v.Type().Field(i), v.Field(i)
+		_, hasFlag := field.Tag.Lookup("flag")
+		_, hasArg := field.Tag.Lookup("arg")
+		// 未导出的字段无法通过反射取得指针
+		if (hasFlag || hasArg) && !field.IsExported() {
+			return fmt.Errorf("field '%s' is unexported and cannot be bound", field.Name)
+		}
+		if tag, ok := field.Tag.Lookup("flag"); ok {
+			if err := c.bindFlag(field, value, tag); err != nil {
+				return err
+			}
+		}
+		if tag, ok := field.Tag.Lookup("arg"); ok {
+			if err := c.bindArg(field, value, tag); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// 根据 flag 标签为字段注册局部 flag
+func (c *Command) bindFlag(field reflect.StructField, value reflect.Value, tag string) error {
+	parts := strings.SplitN(tag, ",", 3)
+	for len(parts) < 3 {
+		parts = append(parts, "")
+	}
+	name, shorthand, usage := parts[0], parts[1], parts[2]
+	if name == "" {
+		return fmt.Errorf("field '%s' has an empty flag name", field.Name)
+	}
+	flags := c.LocalFlags()
+	switch p := value.Addr().Interface().(type) {
+	case *string:
+		flags.StringVarP(p, name, shorthand, *p, usage)
+	case *bool:
+		flags.BoolVarP(p, name, shorthand, *p, usage)
+	case *int:
+		flags.IntVarP(p, name, shorthand, *p, usage)
+	case *float64:
+		flags.Float64VarP(p, name, shorthand, *p, usage)
+	case *time.Duration:
+		flags.DurationVarP(p, name, shorthand, *p, usage)
+	case *[]string:
+		flags.StringSliceVarP(p, name, shorthand, *p, usage)
+	default:
+		return fmt.Errorf("field '%s' has unsupported flag type %s", field.Name, field.Type)
+	}
+	if required, _ := strconv.ParseBool(field.Tag.Get("required")); required {
+		flags.SetAnnotation(name, FlagRequiredAnnotation, []string{"true"})
+	}
+	return nil
+}
+
+// 根据 arg 标签为字段绑定位置参数
+func (c *Command) bindArg(field reflect.StructField, value reflect.Value, tag string) error {
+	index, err := strconv.Atoi(tag)
+	if err != nil || index < 0 {
+		return fmt.Errorf("field '%s' has invalid arg index '%s'", field.Name, tag)
+	}
+	switch p := value.Addr().Interface().(type) {
+	case *string:
+		c.argBindings = append(c.argBindings, argBinding{index: index, kind: "a string", set: func(v string) error {
+			*p = v
+			return nil
+		}})
+	case *int:
+		c.BindArgInt(index, p)
+	case *time.Duration:
+		c.BindArgDuration(index, p)
+	default:
+		return fmt.Errorf("field '%s' has unsupported arg type %s", field.Name, field.Type)
+	}
+	return nil
+}
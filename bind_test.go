@@ -0,0 +1,74 @@
+package bobra
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+// 测试 Bind 根据字段标签注册 flags 和位置参数，并在 Run 之前填充结构体
+func TestCommand_Bind(t *testing.T) {
+	var opts struct {
+		Replicas int           `flag:"replicas,r,number of replicas"`
+		Force    bool          `flag:"force,,skip confirmation"`
+		Timeout  time.Duration `flag:"timeout,,wait timeout, e.g. 30s"`
+		Labels   []string      `flag:"label,l,labels to apply" required:"true"`
+		Name     string        `arg:"0"`
+		Port     int           `arg:"1"`
+		ignored  string
+	}
+	opts.Replicas = 1
+
+	var got []interface{}
+	r := &Command{Use: "root"}
+	sub := &Command{
+		Use: "deploy <name> <port>",
+		Run: func(cmd *Command, args []string) {
+			got = []interface{}{opts.Replicas, opts.Force, opts.Timeout, opts.Labels, opts.Name, opts.Port}
+		},
+	}
+	r.AddCommand(sub)
+	if err := sub.Bind(&opts); err != nil {
+		t.Fatal(err)
+	}
+
+	f := sub.Flags().Lookup("timeout")
+	if f == nil || f.Usage != "wait timeout, e.g. 30s" || sub.Flags().Lookup("replicas").DefValue != "1" {
+		t.Errorf("expected flags registered from struct tags")
+	}
+	if !isFlagRequired(sub.Flags().Lookup("label")) {
+		t.Errorf("expected --label to be required")
+	}
+
+	if err := r.ExecuteLine("deploy -r 3 --force --timeout 1m -l a,b web 8080"); err != nil {
+		t.Fatal(err)
+	}
+	expected := []interface{}{3, true, time.Minute, []string{"a", "b"}, "web", 8080}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected '%v' but got '%v'", expected, got)
+	}
+
+	if err := sub.Bind(opts); err == nil {
+		t.Errorf("expected error for non-pointer value")
+	}
+	var bad struct {
+		Ch chan int `flag:"ch"`
+	}
+	if err := sub.Bind(&bad); err == nil {
+		t.Errorf("expected error for unsupported field type")
+	}
+	var unexportedFlag struct {
+		name string `flag:"name"`
+	}
+	expectedErr := "field 'name' is unexported and cannot be bound"
+	if err := sub.Bind(&unexportedFlag); err == nil || err.Error() != expectedErr {
+		t.Errorf("expected '%s' but got '%v'", expectedErr, err)
+	}
+	var unexportedArg struct {
+		port int `arg:"0"`
+	}
+	expectedErr = "field 'port' is unexported and cannot be bound"
+	if err := sub.Bind(&unexportedArg); err == nil || err.Error() != expectedErr {
+		t.Errorf("expected '%s' but got '%v'", expectedErr, err)
+	}
+}
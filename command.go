@@ -5,13 +5,46 @@ bobra 中实现了精简版的 cobra 的功能, 使得命令行程序开发者
 package bobra
 
 import (
+	"bufio"
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 	flag "github.com/spf13/pflag"
 )
 
+// 每次执行命令解析 flags 之前调用的函数，按照注册顺序调用
+var initializers []func()
+
+// 每次执行命令结束之后调用的函数，按照注册顺序调用
+var finalizers []func()
+
+// 注册在每次执行命令解析 flags 之前调用的函数，如集中加载配置
+func OnInitialize(fns ...func()) {
+	initializers = append(initializers, fns...)
+}
+
+// 注册在每次执行命令结束之后调用的函数，命令执行出错时同样会被调用
+func OnFinalize(fns ...func()) {
+	finalizers = append(finalizers, fns...)
+}
+
+// 父命令链上有多个 persistent 钩子时的执行策略
+type HookInheritance int
+
+const (
+	// 只执行最近的祖先命令的 persistent 钩子，为默认策略
+	InheritNearestHook HookInheritance = iota
+	// 执行全部祖先命令的 persistent 钩子
+	InheritAllHooks
+)
+
 type Command struct {
 	// 命令的使用名称
 	Use string
@@ -21,10 +54,24 @@ type Command struct {
 	Long string
 	// 命令使用介绍
 	Example string
-	// 这个命令对应的全部flags,为 globalflags + localflags
+	// 命令的版本，设置后会显示在使用方法的开头
+	Version string
+	// 显示在使用方法末尾的附加说明，如相关链接、注意事项等
+	UsageFooter string
+	// 为 true 时，使用方法中会额外显示局部 flags 默认值的 Defaults 部分
+	ShowFlagDefaults bool
+	// 为 true 时，该命令不会出现在使用方法和命令索引中，但仍然可以执行
+	Hidden bool
+	// 不为空时表示该命令已废弃，执行时输出该信息，且不会出现在使用方法中
+	Deprecated string
+	// 返回 false 时该命令被禁用，既不会出现在使用方法中，也无法被找到和执行，可用于功能开关
+	Enabled func() bool
+	// 命令的附加标注，供外部工具按键值对筛选命令
+	Annotations map[string]string
+	// 这个命令对应的全部flags,为 localflags + persistentflags + 继承自祖先命令的 persistentflags
 	flags *flag.FlagSet
-	// 这个命令集合对应的全部全局可用的flag
-	globalflags *flag.FlagSet
+	// 这个命令及其全部子命令都可以使用的flag
+	persistentflags *flag.FlagSet
 	// 这个命令集合对应的局部可用的flag，即仅当前命令可以使用的flag
 	localflags *flag.FlagSet
 
@@ -40,13 +87,155 @@ type Command struct {
 
 	// 运行这个命令执行的函数
 	Run func(cmd *Command, args []string)
+	// 运行这个命令执行的函数，可以返回错误，设置时优先于 Run
+	RunE func(cmd *Command, args []string) error
+	// 在 Run 之前执行的函数，如打开数据库连接
+	PreRun func(cmd *Command, args []string)
+	// 在 Run 成功之后执行的函数，如刷新缓冲区
+	PostRun func(cmd *Command, args []string)
+	// 在 PreRun 之前执行的函数，子命令没有设置时会使用最近的祖先命令设置的函数
+	PersistentPreRun func(cmd *Command, args []string)
+	// 在 PostRun 之后执行的函数，子命令没有设置时会使用最近的祖先命令设置的函数
+	PersistentPostRun func(cmd *Command, args []string)
+	// 在其他函数之后执行，无论 PreRunE、RunE 等函数是否返回错误都会执行，用于清理临时目录、释放锁等
+	PostRunAlways func(cmd *Command, args []string)
+	// 父命令链上有多个 persistent 钩子时的执行策略，只对根命令生效
+	InheritHooks HookInheritance
+	// 可以返回错误的 PreRun，设置时优先于 PreRun，返回错误时终止执行
+	PreRunE func(cmd *Command, args []string) error
+	// 可以返回错误的 PostRun，设置时优先于 PostRun
+	PostRunE func(cmd *Command, args []string) error
+	// 可以返回错误的 PersistentPreRun，设置时优先于同一命令的 PersistentPreRun，返回错误时终止执行
+	PersistentPreRunE func(cmd *Command, args []string) error
+	// 可以返回错误的 PersistentPostRun，设置时优先于同一命令的 PersistentPostRun
+	PersistentPostRunE func(cmd *Command, args []string) error
+
+	// 在解析 flags 之前调用，返回的参数列表替代原来的参数列表交给 pflag 解析，可用于展开别名、注入默认参数
+	PreParse func(cmd *Command, args []string) []string
+
+	// 通过 UseMiddleware 注册的中间件，作用于该命令及其全部子命令
+	middlewares []Middleware
+
+	// 校验位置参数的函数，为空时根据 Use 中声明的位置参数校验数量，没有声明时接受任意位置参数
+	Args PositionalArgs
+	// 允许的位置参数取值，配合 OnlyValidArgs 使用
+	ValidArgs []string
+	// 动态返回位置参数的候选值，用于补全，没有设置 ValidArgs 时也用于 OnlyValidArgs 的校验
+	ValidArgsFunction func(cmd *Command, args []string, toComplete string) ([]string, ShellCompDirective)
+	// 为 true 时，解析到该命令后剩余的全部参数（包括未知的 flags）原样作为位置参数交给 Run，
+	// 已知的 flags 仍然会被解析，适用于包装外部工具的命令
+	Passthrough bool
+	// 为 true 时，该命令完全不解析 flags，解析到该命令后剩余的全部参数原样作为位置参数交给 Run，
+	// 适用于把参数交给内嵌的解释器或外部程序的命令
+	DisableFlagParsing bool
+	// 解析 flags 时可以忽略的错误，设置 UnknownFlags 时忽略未知的 flags 而不是报错，
+	// 被忽略的 flags 可以通过 UnknownFlags 取得并转交给其他程序
+	FParseErrWhitelist FParseErrWhitelist
+	// 为 true 时，输入来自终端且缺少 Use 中声明的位置参数时提示用户输入，而不是直接校验失败
+	PromptMissingArgs bool
+	// 本次执行中通过提示用户输入补充的位置参数
+	promptedArgs []string
+	// 为 true 时，值为 "-" 的位置参数被替换为从输入读取的全部内容，末尾的换行符会被去掉
+	ReadStdinArgs bool
+	// 本次执行中从输入读取的内容，为 nil 时还没有读取
+	stdinArg *string
+	// 为 true 时，第一个位置参数之后的参数都不再解析为 flags，由 SetInterspersed 设置
+	interspersedDisabled bool
+	// 通过 BindArgInt 等函数注册的位置参数绑定
+	argBindings []argBinding
+
+	// 在全部内置校验之后、Run 之前执行，用于校验 flags 与位置参数的组合状态
+	PreRunValidate func(cmd *Command, args []string) error
+
+	// 为 true 时，执行出错不再输出使用方法
+	SilenceUsage bool
+
+	// 为 true 时，输出到终端的使用方法中的 URL 会被渲染为 OSC 8 超链接，子命令继承该设置
+	EnableHyperlinks bool
+
+	// 为 true 时，执行期间收到 SIGINT 或 SIGTERM 会取消要执行的命令的上下文，只对执行的根命令生效
+	HandleSignals bool
+	// 设置了 HandleSignals 时，收到信号后、取消上下文之前调用的清理函数
+	OnInterrupt func(sig os.Signal)
+
+	// 为 true 时，参数列表中 @file 形式的参数在解析之前被替换为文件中的参数，只对执行的根命令生效
+	ExpandResponseFiles bool
+
+	// 为 true 时，输入的子命令不存在且输入输出都是终端时，列出名称相近的子命令供用户选择并执行选中的命令，
+	// 只对执行的根命令生效
+	EnableInteractiveSuggestions bool
+
+	// 为 true 时，执行期间的 panic 会被恢复并作为 PanicError 返回，只对执行的根命令生效
+	RecoverPanics bool
+	// 设置了 RecoverPanics 时，恢复 panic 之后调用的函数
+	panicHandler func(cmd *Command, recovered interface{})
+	// 接收每次执行开始和结束事件的 Instrumenter，只对执行的根命令生效
+	instrumenter Instrumenter
+	// 本次执行中通过 OnShutdown 注册的清理函数
+	shutdownHooks []func()
+	// 本次执行的 panic 是否会被恢复，由执行的根命令的 RecoverPanics 决定
+	recoverPanics bool
 
 	// 该 Command 的使用方法介绍
 	usageFunc func(*Command) error
+	// 处理 flags 解析错误的函数
+	flagErrorFunc func(*Command, error) error
+
+	// 根命令是否启用了 --config 参数
+	configEnabled bool
+	// 根命令通过 LoadConfigs 读取的配置
+	configValues map[string]string
+	// 根命令通过 SetConfigResolver 设置的配置解析函数
+	configResolver ConfigResolver
+	// 根命令是否启用了 --help-json 参数
+	helpJSONEnabled bool
+
+	// 命令的输入，为空时使用父命令的输入或标准输入
+	in io.Reader
+
+	// 程序的名称，为空时使用根命令的名称
+	programName string
+	// CommandPath 中命令名称之间的分隔符，为空时使用 ' '
+	commandPathSeparator string
+
+	// 驱动本次执行的完整参数列表
+	invocationArgs []string
+	// 本次执行中交给该命令解析的原始参数列表，包含 flags
+	rawArgs []string
+	// 本次执行的上下文，由 ExecuteContext 设置并传递给要执行的子命令
+	ctx context.Context
+	// 由 SetArgs 注入的参数列表，不包含程序名称，为 nil 时使用 os.Args
+	args []string
+
+	// flag 名称到其取值转换函数的映射
+	flagTransforms map[string]func(string) (string, error)
+	// 已废弃的 flag 名称到替代它的 flag 名称的映射
+	flagRenames map[string]string
+	// flag 名称到它所依赖的 flags 名称的映射
+	flagDependencies map[string][]string
+	// 必须同时指定的 flags 分组
+	flagsRequiredTogether [][]string
+	// 至少需要指定一个的 flags 分组
+	flagsOneRequired [][]string
+	// BindFlagsToEnv 设置的环境变量前缀，nil 表示没有绑定环境变量
+	envPrefix *string
+	// 本次执行中 flags 取值的来源
+	flagSources map[string]FlagSource
+	// 根命令通过 SetValuePrecedence 设置的 flag 取值来源的优先级，为 nil 时使用默认的优先级
+	valuePrecedence []FlagSource
+	// flag 名称到补全其取值的函数的映射
+	flagCompletionFuncs map[string]FlagCompletionFunc
+	// 通过 FlagGroup 创建的局部 flags 分组
+	flagGroups []FlagSection
+	// flag 名称到校验其取值的函数的映射
+	flagValidators map[string][]func(string) error
 }
 
 // 将args参数转换为flags参数
 func (c *Command) ParseFlags(args []string) error {
+	if c.PreParse != nil {
+		args = c.PreParse(c, args)
+	}
 
 	if c.flagErrorBuf == nil {
 		c.flagErrorBuf = new(bytes.Buffer)
@@ -54,39 +243,404 @@ func (c *Command) ParseFlags(args []string) error {
 
 	beforeBufferLen := c.flagErrorBuf.Len()
 
-	c.inheritGlobalFlags()
+	c.Flags().ParseErrorsWhitelist.UnknownFlags = c.Passthrough || c.FParseErrWhitelist.UnknownFlags
+	c.Flags().SetInterspersed(!c.interspersedDisabled)
 	err := c.Flags().Parse(args)
 	if c.flagErrorBuf.Len()-beforeBufferLen > 0 && err == nil {
 		fmt.Println(c.flagErrorBuf.String())
 	}
-	return err
+	if err != nil {
+		tracef("parsing flags [%s] of '%s' failed: %v", strings.Join(args, " "), c.CommandPath(), err)
+		return FlagParseError{Command: c.CommandPath(), Err: err}
+	}
+	c.traceFlags()
+	return nil
 }
 
-// 根据flag参数执行该命令
+// 根据flag参数执行该命令，依次执行 Validate 和 Run 两个阶段，Run 只收到位置参数，并在前后调用 OnInitialize 和 OnFinalize 注册的函数
 func (c *Command) execute(a []string) error {
+	for _, fn := range initializers {
+		fn()
+	}
+	defer func() {
+		for _, fn := range finalizers {
+			fn()
+		}
+	}()
 
-	err := c.ParseFlags(a)
-	if err != nil {
+	if c.Deprecated != "" {
+		fmt.Printf("Command %q is deprecated, %s\n", c.Name(), c.Deprecated)
+	}
+
+	if err := Validate(c, a); err != nil {
 		return err
 	}
-	c.Run(c, a)
-	return nil
+	if c.helpJSONRequested() {
+		return c.printHelpJSON(os.Stdout)
+	}
+	return Run(c, c.positionalArgs())
 }
 
-// 找到要执行的命令，或者抛出异常
-func (c *Command) ExecuteC() (err error) {
-	args := os.Args
+// 执行流程的第一阶段：根据参数列表找到要执行的命令以及它的 flags 参数，并记录本次执行的参数
+func (c *Command) Resolve(args []string) (*Command, []string, error) {
 	cmd, flags, err := c.Find(args)
-	if err == FoundHelp {
-		cmd.Usage()
+	cmd.invocationArgs = append([]string{}, args...)
+	traceResolve(args, cmd, err)
+	return cmd, flags, err
+}
+
+// 执行流程的第二阶段：解析 cmd 的 flags 参数，并执行全部校验，校验失败时返回错误。
+// 设置了 DisableFlagParsing 时跳过 flags 的解析和校验，只校验位置参数
+func Validate(cmd *Command, args []string) error {
+	cmd.rawArgs = args
+	cmd.promptedArgs = nil
+	cmd.stdinArg = nil
+	cmd.flagSources = map[string]FlagSource{}
+	if !cmd.DisableFlagParsing {
+		if err := cmd.ParseFlags(args); err != nil {
+			return cmd.FlagErrorFunc()(cmd, err)
+		}
+		cmd.applyFlagRenames()
+		// 只输出 JSON 格式的使用方法时不需要校验
+		if cmd.helpJSONRequested() {
+			return nil
+		}
+		config, err := cmd.loadConfig()
+		if err != nil {
+			return err
+		}
+		if err := cmd.applyFlagSources(config); err != nil {
+			return cmd.validationFailed(err)
+		}
+		if err := cmd.transformFlags(); err != nil {
+			return cmd.validationFailed(err)
+		}
+		if err := cmd.validateFlagDependencies(); err != nil {
+			return cmd.validationFailed(err)
+		}
+		if err := cmd.validateRequiredFlags(); err != nil {
+			return cmd.validationFailed(err)
+		}
+		if err := cmd.validateFlagGroups(); err != nil {
+			return cmd.validationFailed(err)
+		}
+		if err := cmd.validateFlagValues(); err != nil {
+			return cmd.validationFailed(err)
+		}
+	}
+	cmd.resolveUnsetFlagDefaults()
+	if cmd.PromptMissingArgs {
+		cmd.promptMissingArgs()
+	}
+	if cmd.ReadStdinArgs {
+		if err := cmd.readStdinArg(); err != nil {
+			return cmd.validationFailed(err)
+		}
+	}
+	if validateArgs := cmd.argsValidator(); validateArgs != nil {
+		if err := validateArgs(cmd, cmd.positionalArgs()); err != nil {
+			return cmd.validationFailed(err)
+		}
+	}
+	if err := cmd.applyArgBindings(cmd.positionalArgs()); err != nil {
+		return cmd.validationFailed(err)
+	}
+	if cmd.PreRunValidate != nil {
+		if err := cmd.PreRunValidate(cmd, cmd.positionalArgs()); err != nil {
+			return cmd.validationFailed(err)
+		}
+	}
+	return nil
+}
+
+// 执行流程的第三阶段：依次调用 cmd 的 PersistentPreRun、PreRun、经过中间件包装的 RunE 或 Run、PostRun、PersistentPostRun 函数，
+// 根命令的 InheritHooks 为 InheritAllHooks 时，PersistentPreRun 由外到内、PersistentPostRun 由内到外调用全部祖先命令的函数，
+// 每个阶段都优先使用对应的 *E 函数，任一函数返回错误时不再调用之后的函数，但 PostRunAlways 总会在最后调用。
+// 执行的根命令设置了 RecoverPanics 时，PersistentPreRun 之后发生的 panic 被恢复之前仍然会调用 PersistentPostRun。
+// PersistentPreRunE 或 PreRunE 返回 ErrSkipRun 时不再调用之后的函数（PostRunAlways 除外）并返回 nil。
+// cmd 不能运行时输出它的使用方法
+func Run(cmd *Command, args []string) error {
+	if !cmd.Runnable() {
+		return cmd.Usage()
+	}
+	if cmd.PostRunAlways != nil {
+		defer cmd.PostRunAlways(cmd, args)
+	}
+	owners := cmd.hookOwners(func(p *Command) bool { return p.PersistentPreRunE != nil || p.PersistentPreRun != nil })
+	for i := len(owners) - 1; i >= 0; i-- {
+		if p := owners[i]; p.PersistentPreRunE != nil {
+			traceHook("PersistentPreRunE", p)
+			if err := p.PersistentPreRunE(cmd, args); err != nil {
+				return skipRun(err)
+			}
+		} else {
+			traceHook("PersistentPreRun", p)
+			p.PersistentPreRun(cmd, args)
+		}
+	}
+	postHooksCalled := false
+	defer func() {
+		if r := recover(); r != nil {
+			if cmd.recoverPanics && !postHooksCalled {
+				cmd.runPersistentPostHooks(args)
+			}
+			panic(r)
+		}
+	}()
+	if cmd.PreRunE != nil {
+		traceHook("PreRunE", cmd)
+		if err := cmd.PreRunE(cmd, args); err != nil {
+			return skipRun(err)
+		}
+	} else if cmd.PreRun != nil {
+		traceHook("PreRun", cmd)
+		cmd.PreRun(cmd, args)
+	}
+	traceHook("Run", cmd)
+	if err := cmd.wrapMiddlewares(cmd.runFunc())(cmd, args); err != nil {
+		return err
+	}
+	if cmd.PostRunE != nil {
+		traceHook("PostRunE", cmd)
+		if err := cmd.PostRunE(cmd, args); err != nil {
+			return err
+		}
+	} else if cmd.PostRun != nil {
+		traceHook("PostRun", cmd)
+		cmd.PostRun(cmd, args)
+	}
+	postHooksCalled = true
+	return cmd.runPersistentPostHooks(args)
+}
+
+// 预处理函数返回的 err 为 ErrSkipRun 时返回 nil，否则原样返回
+func skipRun(err error) error {
+	if errors.Is(err, ErrSkipRun) {
+		tracef("skipping run: %v", err)
 		return nil
 	}
+	return err
+}
+
+// 由近到远调用 c 的 PersistentPostRunE 或 PersistentPostRun 函数，返回错误时不再调用之后的函数
+func (c *Command) runPersistentPostHooks(args []string) error {
+	owners := c.hookOwners(func(p *Command) bool { return p.PersistentPostRunE != nil || p.PersistentPostRun != nil })
+	for _, p := range owners {
+		if p.PersistentPostRunE != nil {
+			traceHook("PersistentPostRunE", p)
+			if err := p.PersistentPostRunE(c, args); err != nil {
+				return err
+			}
+		} else {
+			traceHook("PersistentPostRun", p)
+			p.PersistentPostRun(c, args)
+		}
+	}
+	return nil
+}
 
+// 从 c 开始沿父命令链返回满足 has 的命令，由近到远排列。
+// 根命令的 InheritHooks 为 InheritNearestHook 时只返回最近的一个
+func (c *Command) hookOwners(has func(p *Command) bool) []*Command {
+	owners := []*Command{}
+	for p := c; p != nil; p = p.parent {
+		if has(p) {
+			owners = append(owners, p)
+			if c.Root().InheritHooks != InheritAllHooks {
+				break
+			}
+		}
+	}
+	return owners
+}
+
+// 打印校验错误，如果没有设置 SilenceUsage 则同时输出使用方法
+func (c *Command) validationFailed(err error) error {
+	LogError(err)
+	if !c.SilenceUsage {
+		c.Usage()
+	}
+	return err
+}
+
+// 使用给定的上下文执行命令，Run 和 RunE 可以通过 Context 获取它以感知取消和超时
+func (c *Command) ExecuteContext(ctx context.Context) error {
+	c.ctx = ctx
+	return c.Execute()
+}
+
+// 返回本次执行的上下文，未设置时返回 context.Background()
+func (c *Command) Context() context.Context {
+	if c.ctx == nil {
+		return context.Background()
+	}
+	return c.ctx
+}
+
+// 设置执行时使用的参数列表以替代 os.Args，与 os.Args[1:] 一样不包含程序名称，主要用于测试
+func (c *Command) SetArgs(args []string) {
+	c.args = args
+}
+
+// 找到要执行的命令并执行，返回实际执行的命令，或者抛出异常。优先使用 SetArgs 设置的参数列表，未设置时使用 os.Args[1:]，
+// 程序的路径不影响命令的查找
+func (c *Command) ExecuteC() (cmd *Command, err error) {
+	args := c.args
+	if args == nil && len(os.Args) > 0 {
+		args = os.Args[1:]
+	}
+	return c.executeArgs(append([]string{c.Name()}, args...), args)
+}
+
+// 将一整行输入按照 shell 的规则切分为参数后执行，适用于 REPL 等场景
+func (c *Command) ExecuteLine(line string) error {
+	args, err := splitArgs(line)
 	if err != nil {
 		LogError(err)
 		return err
 	}
-	return cmd.execute(flags)
+	_, err = c.executeArgs(append([]string{c.Name()}, args...), args)
+	return err
+}
+
+// 依次执行每组参数，每组参数与 SetArgs 一样不包含程序名称，执行之前通过 Reset 清除上一次执行的 flags 状态。
+// 返回每次执行的错误，下标与 argSets 对应，执行成功时为 nil，适用于在 CI 中批量校验调用方式
+func (c *Command) ExecuteBatch(argSets [][]string) []error {
+	errs := make([]error, len(argSets))
+	for i, args := range argSets {
+		c.Reset()
+		_, errs[i] = c.executeArgs(append([]string{c.Name()}, args...), args)
+	}
+	return errs
+}
+
+// 设置 RecoverPanics 恢复 panic 之后调用的函数，用于记录日志等
+func (c *Command) SetPanicHandler(handler func(cmd *Command, recovered interface{})) {
+	c.panicHandler = handler
+}
+
+// 根据参数列表找到要执行的命令并执行，即 Resolve、Validate 和 Run 三个阶段的组合，返回实际执行的命令。
+// 第一个参数为 ShellCompRequestCmd 时不执行命令，而是按照补全协议输出补全的候选值。
+// invocation 为调用者提供的参数列表，记录为命令的 InvocationArgs
+func (c *Command) executeArgs(args []string, invocation []string) (cmd *Command, err error) {
+	if len(args) > 1 && args[1] == ShellCompRequestCmd {
+		return c, c.complete(os.Stdout, args[2:])
+	}
+	if c.ExpandResponseFiles {
+		if args, err = expandResponseFiles(args); err != nil {
+			LogError(err)
+			return c, err
+		}
+	}
+	cmd, flags, err := c.Resolve(args)
+	if unknown, ok := err.(UnknownCommandError); ok && c.EnableInteractiveSuggestions {
+		if n := len(unknown.Suggestions); n > 0 && n <= maxInteractiveSuggestions {
+			if choice, ok := promptSuggestion(cmd, unknown); ok {
+				args = replaceFirstMatchStr(args, unknown.Name, choice)
+				cmd, flags, err = c.Resolve(args)
+			}
+		}
+	}
+	cmd.invocationArgs = append([]string{}, invocation...)
+	if err == FoundHelp {
+		cmd.Usage()
+		return cmd, nil
+	}
+
+	if err != nil {
+		LogError(err)
+		return cmd, err
+	}
+	cmd.ctx = c.ctx
+	if c.instrumenter != nil {
+		start := time.Now()
+		c.instrumenter.CommandStarted(cmd)
+		defer func() { c.instrumenter.CommandFinished(cmd, err, time.Since(start)) }()
+	}
+	if c.RecoverPanics {
+		cmd.recoverPanics = true
+		defer func() {
+			cmd.recoverPanics = false
+			if r := recover(); r != nil {
+				if c.panicHandler != nil {
+					c.panicHandler(cmd, r)
+				}
+				err = PanicError{Command: cmd.CommandPath(), Value: r}
+			}
+		}()
+	}
+	if c.HandleSignals {
+		stop := cmd.notifySignals(c.OnInterrupt)
+		defer stop()
+	}
+	defer cmd.shutdown()
+	err = cmd.execute(flags)
+	return cmd, err
+}
+
+// 返回驱动该命令最近一次执行的参数列表，与调用者提供的相同：通过 SetArgs、ExecuteLine 或 ExecuteBatch 执行时
+// 不包含程序名称，使用 os.Args 执行时为 os.Args[1:]，直接调用 Resolve 时为传入的参数列表
+func (c *Command) InvocationArgs() []string {
+	return c.invocationArgs
+}
+
+// 返回交给 Run 的位置参数，设置了 Passthrough 或 DisableFlagParsing 时为原始参数列表，否则为去除 flags 后的参数，
+// 之后是通过提示用户输入补充的位置参数。设置了 ReadStdinArgs 时值为 "-" 的参数被替换为从输入读取的内容
+func (c *Command) positionalArgs() []string {
+	args := c.Flags().Args()
+	if c.Passthrough || c.DisableFlagParsing {
+		args = c.rawArgs
+	}
+	args = append(append([]string{}, args...), c.promptedArgs...)
+	if c.ReadStdinArgs && c.stdinArg != nil {
+		for i, arg := range args {
+			if arg == "-" {
+				args[i] = *c.stdinArg
+			}
+		}
+	}
+	return args
+}
+
+// 返回本次执行中交给该命令解析的原始参数列表，包含 flags，而 Run 收到的只有位置参数
+func (c *Command) RawArgs() []string {
+	return c.rawArgs
+}
+
+// 设置是否允许 flags 出现在位置参数之后，默认允许。不允许时第一个位置参数及其之后的参数都原样作为位置参数，
+// 适用于 "cli run image args-for-container" 这样把剩余参数交给其他程序的命令
+func (c *Command) SetInterspersed(interspersed bool) {
+	c.interspersedDisabled = !interspersed
+}
+
+// 返回 "--" 之前的位置参数的数量，即 "--" 之后的参数在 Run 收到的位置参数中的起始下标，没有 "--" 时返回 -1
+func (c *Command) ArgsLenAtDash() int {
+	return c.Flags().ArgsLenAtDash()
+}
+
+// 设置命令的输入，用于在测试中替代标准输入
+func (c *Command) SetIn(in io.Reader) {
+	c.in = in
+}
+
+// 返回命令的输入，未设置时依次使用父命令的输入和标准输入
+func (c *Command) InOrStdin() io.Reader {
+	for p := c; p != nil; p = p.parent {
+		if p.in != nil {
+			return p.in
+		}
+	}
+	return os.Stdin
+}
+
+// 判断命令的输入是否来自管道或文件重定向而不是终端
+func (c *Command) StdinIsPipe() bool {
+	f, ok := c.InOrStdin().(*os.File)
+	if !ok {
+		return true
+	}
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice == 0
 }
 
 // 返回当前命令的父命令
@@ -96,46 +650,74 @@ func (c *Command) Parent() *Command {
 
 // 执行命令，调用链为：Execute--->ExecuteC--->execute
 func (c *Command) Execute() error {
-	err := c.ExecuteC()
+	_, err := c.ExecuteC()
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
+// 结束进程的函数，测试时可以替换
+var osExit = os.Exit
+
+// 执行 root 并以错误对应的退出码结束进程，命令返回的 ExitError 决定退出码，其他错误的退出码为 1
+func ExecuteAndExit(root *Command) {
+	osExit(exitCode(root.Execute()))
+}
+
 // 设置全局可用的flags
+//
+// Deprecated: 使用 Root().PersistentFlags() 注册全局可用的 flags
 func (c *Command) SetGlobalFlags(flags *flag.FlagSet) {
-	c.globalflags = flags
+	c.Root().persistentflags = flags
 }
 
-// 获取全局的flags
+// 获取全局的flags，即根命令的 persistent flags
+//
+// Deprecated: 使用 Root().PersistentFlags()，或者在需要的子命令上使用 PersistentFlags()
 func (c *Command) GlobalFlags() *flag.FlagSet {
-	c.inheritGlobalFlags()
+	return c.Root().PersistentFlags()
+}
 
-	if c.globalflags == nil {
-		c.globalflags = flag.NewFlagSet(c.Name(), flag.ContinueOnError)
+// 返回该命令及其全部子命令都可以使用的 flags，子命令在解析时继承全部祖先命令的 persistent flags
+func (c *Command) PersistentFlags() *flag.FlagSet {
+	if c.persistentflags == nil {
+		c.persistentflags = flag.NewFlagSet(c.Name(), flag.ContinueOnError)
 		if c.flagErrorBuf == nil {
 			c.flagErrorBuf = new(bytes.Buffer)
 		}
-		c.globalflags.SetOutput(c.flagErrorBuf)
+		c.persistentflags.SetOutput(c.flagErrorBuf)
 	}
+	return c.persistentflags
+}
 
-	return c.globalflags
+// 返回继承自祖先命令的 persistent flags，同名时使用离该命令最近的祖先命令的 flag
+func (c *Command) InheritedFlags() *flag.FlagSet {
+	return c.mergePersistentFlags(c.parent)
 }
 
-// 继承了全局的flags
-func (c *Command) inheritGlobalFlags() {
-	// 如果为根命令，终止
-	if c.Parent() == nil {
-		return
+// 返回该命令可以使用的全部 persistent flags，包括自身和祖先命令的，显示在使用方法的 GlobalFlags 部分
+func (c *Command) AvailableGlobalFlags() *flag.FlagSet {
+	return c.mergePersistentFlags(c)
+}
+
+// 返回从 from 开始沿父命令链的全部 persistent flags 的合并集合，同名时近的命令的 flag 优先
+func (c *Command) mergePersistentFlags(from *Command) *flag.FlagSet {
+	merged := flag.NewFlagSet(c.Name(), flag.ContinueOnError)
+	if c.flagErrorBuf == nil {
+		c.flagErrorBuf = new(bytes.Buffer)
 	}
-	// 否则继承父亲的globalflags, 一个指令集下应当维护一个全局唯一的globalflags指针
-	c.globalflags = c.Parent().GlobalFlags()
+	merged.SetOutput(c.flagErrorBuf)
+	for p := from; p != nil; p = p.parent {
+		if p.persistentflags != nil {
+			merged.AddFlagSet(p.persistentflags)
+		}
+	}
+	return merged
 }
 
 // 返回仅子命令可以使用的局部flags
 func (c *Command) LocalFlags() *flag.FlagSet {
-	c.inheritGlobalFlags()
 
 	if c.localflags == nil {
 		c.localflags = flag.NewFlagSet(c.Name(), flag.ContinueOnError)
@@ -144,13 +726,15 @@ func (c *Command) LocalFlags() *flag.FlagSet {
 		}
 		c.localflags.SetOutput(c.flagErrorBuf)
 	}
+	for _, section := range c.flagGroups {
+		c.localflags.AddFlagSet(section.Flags)
+	}
 
 	return c.localflags
 }
 
 // 返回命令的参数列表, 如果 flags 为空则初始化这个flag
 func (c *Command) Flags() *flag.FlagSet {
-	c.inheritGlobalFlags()
 	if c.flags == nil {
 		c.flags = flag.NewFlagSet(c.Name(), flag.ContinueOnError)
 		if c.flagErrorBuf == nil {
@@ -158,23 +742,86 @@ func (c *Command) Flags() *flag.FlagSet {
 		}
 		c.flags.SetOutput(c.flagErrorBuf)
 	}
-	c.flags.AddFlagSet(c.localflags)
-	c.flags.AddFlagSet(c.globalflags)
+	c.flags.AddFlagSet(c.LocalFlags())
+	c.flags.AddFlagSet(c.persistentflags)
+	c.flags.AddFlagSet(c.InheritedFlags())
 
 	return c.flags
 }
 
+// 清除该命令及其全部子命令已解析的 flags 取值、错误输出缓冲区和合并后的 flags，使同一个命令树可以多次执行
+func (c *Command) Reset() {
+	for _, fs := range []*flag.FlagSet{c.localflags, c.persistentflags} {
+		if fs != nil {
+			fs.VisitAll(resetFlag)
+		}
+	}
+	if c.flags != nil {
+		merged := flag.NewFlagSet(c.Name(), flag.ContinueOnError)
+		merged.SetOutput(c.flagErrorBuf)
+		merged.SortFlags = c.flags.SortFlags
+		c.flags.VisitAll(func(f *flag.Flag) {
+			resetFlag(f)
+			merged.AddFlag(f)
+		})
+		c.flags = merged
+	}
+	if c.flagErrorBuf != nil {
+		c.flagErrorBuf.Reset()
+	}
+	c.invocationArgs = nil
+	c.flagSources = nil
+	for _, sub := range c.commands {
+		sub.Reset()
+	}
+}
+
 // 添加子命令
 func (c *Command) AddCommand(cmds ...*Command) {
 	for i, x := range cmds {
 		if cmds[i] == c {
 			panic("Command can't be a child of itself")
 		}
+		// 被添加的命令不能是 c 的祖先，否则会形成环
+		for p := c.parent; p != nil; p = p.parent {
+			if p == x {
+				panic("Command can't be a child of its descendant, cycle: " + c.CommandPath() + " " + x.Name())
+			}
+		}
 		cmds[i].parent = c
 		c.commands = append(c.commands, x)
 	}
 }
 
+// 将 other 的子命令合并为 c 的子命令，other 根命令的全局 flags 会转移到 c 的全局 flags 中。
+// 子命令或 flag 重名时返回错误，且不做任何修改
+func (c *Command) Merge(other *Command) error {
+	for _, sub := range other.commands {
+		if c.findSubCmd(sub.Name()) != nil {
+			return ObjectExists{Type: "Command", Name: sub.Name()}
+		}
+	}
+	globals, otherGlobals := c.Root().PersistentFlags(), other.Root().PersistentFlags()
+	var err error
+	otherGlobals.VisitAll(func(f *flag.Flag) {
+		if err != nil || globals.Lookup(f.Name) == f {
+			return
+		}
+		if globals.Lookup(f.Name) != nil || (f.Shorthand != "" && globals.ShorthandLookup(f.Shorthand) != nil) {
+			err = ObjectExists{Type: "Flag", Name: f.Name}
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	globals.AddFlagSet(otherGlobals)
+	subs := other.commands
+	other.commands = nil
+	c.AddCommand(subs...)
+	return nil
+}
+
 // 递归寻找下一个要执行的子命令，如果找不到则抛出异常
 func innerFind(cmd *Command, innerArgs []string) (*Command, []string, error) {
 
@@ -182,6 +829,12 @@ func innerFind(cmd *Command, innerArgs []string) (*Command, []string, error) {
 	if innerArgs[0] != cmd.Name() {
 		return cmd, nil, ObjectNotFound{Type: "Command", Name: innerArgs[0]}
 	}
+	if !cmd.isEnabled() {
+		return cmd, nil, CommandNotAvailable{Name: cmd.CommandPath()}
+	}
+	if cmd.Passthrough || cmd.DisableFlagParsing {
+		return cmd, innerArgs[1:], nil
+	}
 
 	innerArgsWithoutFlags := stripFlags(innerArgs[1:], cmd)
 
@@ -198,12 +851,66 @@ func innerFind(cmd *Command, innerArgs []string) (*Command, []string, error) {
 
 	subCmd := cmd.findSubCmd(sub)
 	if subCmd == nil {
-		return cmd, nil, ObjectNotFound{Type: "Command", Name: sub}
+		// 声明了位置参数或者没有子命令的命令把剩余的参数作为位置参数，由位置参数的校验决定是否接受，
+		// 不能运行的命令在执行时输出使用方法
+		if cmd.argsValidator() != nil || !cmd.HasSubCommands() {
+			return cmd, innerArgs[1:], nil
+		}
+		return cmd, nil, UnknownCommandError{Command: cmd.CommandPath(), Name: sub, Suggestions: cmd.suggestionsFor(sub)}
 	}
 
 	return innerFind(subCmd, innerArgs[1:])
 }
 
+// 子命令名称与输入的编辑距离不超过该值时作为建议
+const suggestionsMinimumDistance = 2
+
+// 返回与 name 相近的可用子命令名称，按照编辑距离从小到大排列
+func (c *Command) suggestionsFor(name string) []string {
+	type suggestion struct {
+		name     string
+		distance int
+	}
+	found := []suggestion{}
+	for _, sub := range c.commands {
+		if !sub.IsAvailable() {
+			continue
+		}
+		d := levenshtein(strings.ToLower(name), strings.ToLower(sub.Name()))
+		if d <= suggestionsMinimumDistance || strings.HasPrefix(strings.ToLower(sub.Name()), strings.ToLower(name)) {
+			found = append(found, suggestion{name: sub.Name(), distance: d})
+		}
+	}
+	sort.SliceStable(found, func(i, j int) bool { return found[i].distance < found[j].distance })
+	suggestions := []string{}
+	for _, s := range found {
+		suggestions = append(suggestions, s.name)
+	}
+	return suggestions
+}
+
+// 建议的子命令不超过该数量时才会交互式地提示用户选择
+const maxInteractiveSuggestions = 5
+
+// 列出 e 中建议的子命令并读取用户的选择，输入或输出不是终端、用户没有选择时 ok 为 false，测试时可以替换
+var promptSuggestion = func(cmd *Command, e UnknownCommandError) (choice string, ok bool) {
+	in := cmd.InOrStdin()
+	if !isInteractive(in) || !isTerminal(os.Stdout) {
+		return "", false
+	}
+	fmt.Printf("Unknown command '%s' for '%s'. Did you mean:\n", e.Name, e.Command)
+	for i, s := range e.Suggestions {
+		fmt.Printf("  %d) %s\n", i+1, s)
+	}
+	fmt.Print("Enter a number to run it, or press enter to cancel: ")
+	line, _ := bufio.NewReader(in).ReadString('\n')
+	n, err := strconv.Atoi(strings.TrimSpace(line))
+	if err != nil || n < 1 || n > len(e.Suggestions) {
+		return "", false
+	}
+	return e.Suggestions[n-1], true
+}
+
 // 从参数中找到要执行的子命令, 如果没有子命令则返回这个命令本身，如果找不到则返回错误
 func (c *Command) Find(args []string) (*Command, []string, error) {
 	cmd, flags, err := innerFind(c, args)
@@ -240,7 +947,7 @@ func (c *Command) ShortIntroduction() string {
 func (c *Command) Root() *Command {
 	p := c
 	for p.parent != nil {
-		p = c.parent
+		p = p.parent
 	}
 	return p
 }
@@ -249,21 +956,84 @@ func (c *Command) Commands() []*Command {
 	return c.commands
 }
 
-// 返回这条命令从根命令开始向下，直到当前命令c的命令名称组合，用 ' ' 分割
+// 返回标注中 key 对应的值为 value 的直接子命令
+func (c *Command) CommandsByAnnotation(key, value string) []*Command {
+	cmds := []*Command{}
+	for _, sub := range c.commands {
+		if v, ok := sub.Annotations[key]; ok && v == value {
+			cmds = append(cmds, sub)
+		}
+	}
+	return cmds
+}
+
+// 返回这条命令从根命令开始向下，直到当前命令c的命令名称组合，默认用 ' ' 分割
 func (c *Command) CommandPath() string {
 	if c.HasParent() {
-		return c.Parent().CommandPath() + " " + c.Name()
+		return c.Parent().CommandPath() + c.CommandPathSeparator() + c.Name()
 	}
-	return c.Name()
+	return c.ProgramName()
+}
+
+// 返回以空格分隔的命令路径，即在 shell 中调用该命令时输入的形式，不受 SetCommandPathSeparator 影响，用于使用方法
+func (c *Command) UsagePath() string {
+	if c.HasParent() {
+		return c.Parent().UsagePath() + " " + c.Name()
+	}
+	return c.ProgramName()
+}
+
+// 设置 CommandPath 中命令名称之间的分隔符，对整棵命令树生效
+func (c *Command) SetCommandPathSeparator(sep string) {
+	c.Root().commandPathSeparator = sep
+}
+
+// 返回 CommandPath 中命令名称之间的分隔符，默认为 ' '
+func (c *Command) CommandPathSeparator() string {
+	if sep := c.Root().commandPathSeparator; sep != "" {
+		return sep
+	}
+	return " "
+}
+
+// 设置程序的名称，用于二进制文件名与根命令的 Use 不一致的情况，如软链接或重新打包
+func (c *Command) SetProgramName(name string) {
+	c.Root().programName = name
+}
+
+// 返回程序的名称，即使用方法中根命令显示的名称，默认为根命令的 Name
+func (c *Command) ProgramName() string {
+	root := c.Root()
+	if root.programName != "" {
+		return root.programName
+	}
+	return root.Name()
+}
+
+// 返回命令路径与简短介绍组成的一行摘要
+func (c *Command) Summary() string {
+	return rpad(c.CommandPath(), summaryPadding) + " " + c.Short
+}
+
+// 返回命令树中所有未隐藏命令的摘要，按照深度优先的顺序排列
+func (c *Command) CommandIndex() []string {
+	if c.Hidden {
+		return []string{}
+	}
+	index := []string{c.Summary()}
+	for _, sub := range c.commands {
+		index = append(index, sub.CommandIndex()...)
+	}
+	return index
 }
 
 // 输出对于这条命令的完整描述
 func (c *Command) UseLine() string {
 	var useline string
 	if c.HasParent() {
-		useline = c.parent.CommandPath() + " " + c.Use
+		useline = c.parent.UsagePath() + " " + c.Use
 	} else {
-		useline = c.Use
+		useline = c.ProgramName() + c.Use[len(c.Name()):]
 	}
 
 	if c.HasAvailableFlags() && !strings.Contains(useline, "[flags]") {
@@ -282,19 +1052,27 @@ func (c *Command) findSubCmd(cmdUse string) *Command {
 	return nil
 }
 
-// 根据是否存在 Run 函数指针来判断这个命令能否运行
+// 根据是否存在 Run 或 RunE 函数指针来判断这个命令能否运行
 func (c *Command) Runnable() bool {
-	return c.Run != nil
+	return c.Run != nil || c.RunE != nil
 }
 
-// 判断该命令是否有效
+// 判断该命令是否有效，隐藏的、废弃的以及被禁用的命令无效
 func (c *Command) IsAvailable() bool {
+	if c.Hidden || c.Deprecated != "" || !c.isEnabled() {
+		return false
+	}
 	if c.Runnable() || c.HasAvailableSubCmds() {
 		return true
 	}
 	return false
 }
 
+// 判断该命令是否启用，未设置 Enabled 时默认启用
+func (c *Command) isEnabled() bool {
+	return c.Enabled == nil || c.Enabled()
+}
+
 // 判断该命令是否有有效的子命令
 func (c *Command) HasAvailableSubCmds() bool {
 	for _, sub := range c.commands {
@@ -320,14 +1098,12 @@ func (c *Command) HasParent() bool {
 
 // 判断命令是否存在有效的flags
 func (c *Command) HasAvailableFlags() bool {
-	c.inheritGlobalFlags()
 	return c.Flags().HasAvailableFlags()
 }
 
-// 判断命令是否存在全局有效的flags
+// 判断命令是否存在全局有效的flags，即自身或祖先命令的 persistent flags
 func (c *Command) HasAvailableGlobalFlags() bool {
-	c.inheritGlobalFlags()
-	return c.GlobalFlags().HasAvailableFlags()
+	return c.AvailableGlobalFlags().HasAvailableFlags()
 }
 
 // 判断命令是否存在局部有效的flags
@@ -335,6 +1111,16 @@ func (c *Command) HasAvailableLocalFlags() bool {
 	return c.LocalFlags().HasAvailableFlags()
 }
 
+// 返回未隐藏的局部 flags 的数量
+func (c *Command) LocalFlagCount() int {
+	return countVisibleFlags(c.LocalFlags())
+}
+
+// 返回未隐藏的全局 flags 的数量
+func (c *Command) GlobalFlagCount() int {
+	return countVisibleFlags(c.AvailableGlobalFlags())
+}
+
 // 显示命令的使用方法
 func (c *Command) Usage() error {
 	return c.UsageFunc()(c)
@@ -349,8 +1135,7 @@ func (c *Command) UsageFunc() (f func(*Command) error) {
 		return c.Parent().UsageFunc()
 	}
 	return func(c *Command) error {
-		c.inheritGlobalFlags()
-		err := templify(os.Stdout, c.UsageTemplate(), c)
+		err := c.renderUsage(os.Stdout)
 		if err != nil {
 			LogError(err)
 		}
@@ -358,6 +1143,52 @@ func (c *Command) UsageFunc() (f func(*Command) error) {
 	}
 }
 
+// 设置处理 flags 解析错误的函数，fn 返回的错误会替代原来的错误从 Execute 中返回，可以用于翻译错误或追加提示。
+// 没有设置时使用父命令的函数
+func (c *Command) SetFlagErrorFunc(fn func(*Command, error) error) {
+	c.flagErrorFunc = fn
+}
+
+// 返回处理 flags 解析错误的函数，默认原样返回错误
+func (c *Command) FlagErrorFunc() func(*Command, error) error {
+	if c.flagErrorFunc != nil {
+		return c.flagErrorFunc
+	}
+	if c.HasParent() {
+		return c.Parent().FlagErrorFunc()
+	}
+	return func(c *Command, err error) error {
+		return err
+	}
+}
+
+// 将命令的使用方法输出到 w，启用超链接且 w 为终端时，文本中的 URL 会被渲染为可点击的链接
+func (c *Command) renderUsage(w io.Writer) error {
+	buf := new(bytes.Buffer)
+	tracef("rendering usage of '%s'", c.CommandPath())
+	c.resolveFlagDefaults()
+	if err := templify(buf, c.UsageTemplate(), c); err != nil {
+		tracef("rendering usage of '%s' failed: %v", c.CommandPath(), err)
+		return err
+	}
+	out := cleanUsage(buf.String())
+	if c.hyperlinksEnabled() && isTerminal(w) {
+		out = hyperlink(out)
+	}
+	_, err := io.WriteString(w, out)
+	return err
+}
+
+// 判断命令或其祖先命令是否启用了超链接
+func (c *Command) hyperlinksEnabled() bool {
+	for p := c; p != nil; p = p.parent {
+		if p.EnableHyperlinks {
+			return true
+		}
+	}
+	return false
+}
+
 func (c *Command) UsageTemplate() string {
 	if c.usageTemplate != "" {
 		return c.usageTemplate
@@ -366,21 +1197,36 @@ func (c *Command) UsageTemplate() string {
 	if c.HasParent() {
 		return c.parent.UsageTemplate()
 	}
-	return `
-{{.LongIntroduction}}
+	return `{{if .Version}}{{.ProgramName}} version {{.Version}}
+
+{{end}}{{if .DryRun}}Dry-run mode: no changes will be made.
 
-Usage:{{if .Runnable}}
+{{end}}{{with .LongIntroduction}}{{.}}
+
+{{end}}Usage:{{if .Runnable}}
   {{.UseLine}}{{end}}{{if .HasAvailableSubCmds}}
-  {{.CommandPath}} [command]
+  {{.UsagePath}} [command]
 
 Available Commands:{{range .Commands}}{{if .IsAvailable}}
-  {{.Name}}: {{.ShortIntroduction}}{{end}}{{end}}{{end}}{{if .HasAvailableLocalFlags}}
-LocalFlags:
-  {{.LocalFlags.FlagUsages}}
-{{end}}{{if .HasAvailableGlobalFlags}}
-GlobalFlags:
-  {{.GlobalFlags.FlagUsages}}
-{{end}} {{if .HasAvailableSubCmds}}
-Use "{{.CommandPath}} [command] --help" for more information about a command.{{end}}
+  {{.Name}}: {{.ShortIntroduction}}{{end}}{{end}}{{end}}{{with .ArgPlaceholders}}
+
+Arguments:{{range .}}
+  {{.}}{{end}}{{end}}{{if .UngroupedFlagCount}}
+
+LocalFlags ({{.UngroupedFlagCount}}):
+{{.UngroupedFlags.FlagUsages | trimRight}}{{end}}{{range .FlagSections}}
+
+{{.Title}} ({{.Count}}):
+{{.Flags.FlagUsages | trimRight}}{{end}}{{if and .ShowFlagDefaults .HasAvailableLocalFlags}}
+
+Defaults:
+{{.FlagDefaults | trimRight}}{{end}}{{if .HasAvailableGlobalFlags}}
+
+GlobalFlags ({{.GlobalFlagCount}}):
+{{.AvailableGlobalFlags.FlagUsages | trimRight}}{{end}}{{if .HasAvailableSubCmds}}
+
+Use "{{.UsagePath}} [command] --help" for more information about a command.{{end}}{{with .UsageFooter}}
+
+{{.}}{{end}}
 `
 }
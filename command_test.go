@@ -1,8 +1,14 @@
 package bobra
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -171,3 +177,592 @@ func ExampleCommand_CommandPath() {
 	fmt.Println(s2.CommandPath())
 	// Output: root test subtest
 }
+
+// 测试 PreRunValidate 能够拒绝 flags 与位置参数的非法组合
+func TestCommand_PreRunValidate(t *testing.T) {
+	ran := false
+	c := &Command{
+		Use:          "deploy",
+		SilenceUsage: true,
+		Run: func(cmd *Command, args []string) {
+			ran = true
+		},
+		PreRunValidate: func(cmd *Command, args []string) error {
+			force, _ := cmd.Flags().GetBool("force")
+			if force && len(args) > 0 && args[0] == "prod" {
+				return fmt.Errorf("--force can't be used with target 'prod'")
+			}
+			return nil
+		},
+	}
+	c.LocalFlags().BoolP("force", "f", false, "skip confirmation")
+
+	if err := c.execute([]string{"--force", "prod"}); err == nil || ran {
+		t.Errorf("expected validation error and Run not called, but got err '%v', ran '%v'", err, ran)
+	}
+	if err := c.execute([]string{"--force", "staging"}); err != nil || !ran {
+		t.Errorf("expected Run to be called without error, but got err '%v', ran '%v'", err, ran)
+	}
+}
+
+// 测试根据标注筛选子命令
+func TestCommand_CommandsByAnnotation(t *testing.T) {
+	r := &Command{Use: "root"}
+	stable := &Command{Use: "stable", Annotations: map[string]string{"stability": "stable"}}
+	beta := &Command{Use: "beta", Annotations: map[string]string{"stability": "beta"}}
+	plain := &Command{Use: "plain"}
+	r.AddCommand(stable, beta, plain)
+
+	cmds := r.CommandsByAnnotation("stability", "stable")
+	if len(cmds) != 1 || cmds[0] != stable {
+		t.Errorf("expected only 'stable', but got %d commands", len(cmds))
+	}
+}
+
+// 测试执行一整行输入，带引号的参数值保留空格
+func TestCommand_ExecuteLine(t *testing.T) {
+	var name string
+	r := &Command{Use: "mycli"}
+	service := &Command{Use: "service"}
+	start := &Command{
+		Use: "start",
+		Run: func(cmd *Command, args []string) {
+			name, _ = cmd.Flags().GetString("name")
+		},
+	}
+	start.LocalFlags().String("name", "", "service name")
+	r.AddCommand(service)
+	service.AddCommand(start)
+
+	err := r.ExecuteLine(`service start --name "my service"`)
+	expected := "my service"
+	if err != nil || name != expected {
+		t.Errorf("expected '%s' but got '%s', error '%v'", expected, name, err)
+	}
+}
+
+// 测试子命令能够获取驱动本次执行的参数列表
+func TestCommand_InvocationArgs(t *testing.T) {
+	r := &Command{Use: "root"}
+	sub := &Command{
+		Use: "sub",
+		Run: func(cmd *Command, args []string) {},
+	}
+	sub.LocalFlags().Int("count", 0, "count")
+	r.AddCommand(sub)
+
+	// os.Args[0] 为程序的路径时同样能够找到命令
+	os.Args = []string{"/usr/bin/root", "sub", "--count", "3"}
+	if err := r.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(sub.InvocationArgs(), os.Args[1:]) {
+		t.Errorf("expected '%q' but got '%q'", os.Args[1:], sub.InvocationArgs())
+	}
+
+	r.SetArgs([]string{"sub", "--count", "5"})
+	r.Execute()
+	if expected := []string{"sub", "--count", "5"}; !reflect.DeepEqual(sub.InvocationArgs(), expected) {
+		t.Errorf("expected '%q' but got '%q'", expected, sub.InvocationArgs())
+	}
+}
+
+// 测试使用方法的末尾附加说明显示在全局 flags 之后
+func TestCommand_UsageFooter(t *testing.T) {
+	r := &Command{Use: "root"}
+	sub := &Command{
+		Use:         "sub",
+		UsageFooter: "See https://example.com/docs for details.",
+		Run:         func(cmd *Command, args []string) {},
+	}
+	r.AddCommand(sub)
+	r.GlobalFlags().Bool("verbose", false, "verbose output")
+
+	var buf bytes.Buffer
+	if err := templify(&buf, sub.UsageTemplate(), sub); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	footer, global := strings.Index(out, sub.UsageFooter), strings.Index(out, "--verbose")
+	if footer < 0 || global < 0 || footer < global {
+		t.Errorf("expected footer after global flags, but got:\n%s", out)
+	}
+}
+
+// 测试启用超链接后 URL 渲染为 OSC 8 超链接，未启用时保持纯文本
+func TestCommand_EnableHyperlinks(t *testing.T) {
+	defer func(f func(w io.Writer) bool) { isTerminal = f }(isTerminal)
+	isTerminal = func(w io.Writer) bool { return true }
+
+	r := &Command{
+		Use:  "root",
+		Long: "Docs live at https://example.com/docs.",
+		Run:  func(cmd *Command, args []string) {},
+	}
+	link := "\x1b]8;;https://example.com/docs\x1b\\https://example.com/docs\x1b]8;;\x1b\\"
+
+	var buf bytes.Buffer
+	r.renderUsage(&buf)
+	if strings.Contains(buf.String(), "\x1b]8;;") {
+		t.Errorf("expected no hyperlinks when disabled, but got:\n%q", buf.String())
+	}
+
+	r.EnableHyperlinks = true
+	buf.Reset()
+	r.renderUsage(&buf)
+	if !strings.Contains(buf.String(), link+".") {
+		t.Errorf("expected hyperlink '%q', but got:\n%q", link, buf.String())
+	}
+}
+
+// 测试命令索引包含嵌套命令的摘要且不包含隐藏的命令
+func TestCommand_CommandIndex(t *testing.T) {
+	r := &Command{Use: "mycli", Short: "my cli"}
+	service := &Command{Use: "service", Short: "manage services"}
+	start := &Command{Use: "start", Short: "start a service", Run: func(cmd *Command, args []string) {}}
+	internal := &Command{Use: "internal", Short: "internal tools", Hidden: true}
+	debug := &Command{Use: "debug", Short: "debug tools", Run: func(cmd *Command, args []string) {}}
+	r.AddCommand(service, internal)
+	service.AddCommand(start)
+	internal.AddCommand(debug)
+
+	index := r.CommandIndex()
+	expected := []string{
+		"mycli                    my cli",
+		"mycli service            manage services",
+		"mycli service start      start a service",
+	}
+	if !reflect.DeepEqual(index, expected) {
+		t.Errorf("expected '%q' but got '%q'", expected, index)
+	}
+}
+
+// 测试合并两棵命令树后能够找到来自两棵树的命令，且全局 flags 被转移
+func TestCommand_Merge(t *testing.T) {
+	a := &Command{Use: "a"}
+	build := &Command{Use: "build", Run: func(cmd *Command, args []string) {}}
+	a.AddCommand(build)
+
+	b := &Command{Use: "b"}
+	deploy := &Command{Use: "deploy"}
+	prod := &Command{Use: "prod", Run: func(cmd *Command, args []string) {}}
+	b.AddCommand(deploy)
+	deploy.AddCommand(prod)
+	b.GlobalFlags().String("region", "us", "deploy region")
+
+	if err := a.Merge(b); err != nil {
+		t.Fatalf("expected no error but got '%v'", err)
+	}
+	if cmd, _, err := a.Find([]string{"a", "build"}); err != nil || cmd != build {
+		t.Errorf("expected to find 'build' but got '%s', error '%v'", cmd.Name(), err)
+	}
+	cmd, flags, err := a.Find([]string{"a", "deploy", "prod", "--region", "eu"})
+	if err != nil || cmd != prod {
+		t.Fatalf("expected to find 'prod' but got '%s', error '%v'", cmd.Name(), err)
+	}
+	cmd.ParseFlags(flags)
+	if r, _ := cmd.Flags().GetString("region"); r != "eu" || cmd.CommandPath() != "a deploy prod" {
+		t.Errorf("expected region 'eu' on 'a deploy prod', but got '%s' on '%s'", r, cmd.CommandPath())
+	}
+
+	c := &Command{Use: "c"}
+	c.AddCommand(&Command{Use: "build"})
+	if err := a.Merge(c); err != (ObjectExists{Type: "Command", Name: "build"}) {
+		t.Errorf("expected name collision error but got '%v'", err)
+	}
+}
+
+// 测试添加子命令时拒绝形成环
+func TestCommand_AddCommandCycle(t *testing.T) {
+	a := &Command{Use: "a"}
+	b := &Command{Use: "b"}
+	a.AddCommand(b)
+
+	defer func() {
+		r := recover()
+		expected := "Command can't be a child of its descendant, cycle: a b a"
+		if r != expected {
+			t.Errorf("expected panic '%s' but got '%v'", expected, r)
+		}
+	}()
+	b.AddCommand(a)
+}
+
+// 测试设置程序名称后使用方法中的根命令路径使用该名称
+func TestCommand_SetProgramName(t *testing.T) {
+	r := &Command{Use: "mycli [options]", Run: func(cmd *Command, args []string) {}}
+	sub := &Command{Use: "sub <name>", Run: func(cmd *Command, args []string) {}}
+	r.AddCommand(sub)
+
+	if r.UseLine() != "mycli [options]" || sub.CommandPath() != "mycli sub" {
+		t.Errorf("expected default name 'mycli' but got '%s', '%s'", r.UseLine(), sub.CommandPath())
+	}
+	r.SetProgramName("mycli2")
+	if r.UseLine() != "mycli2 [options]" || sub.UseLine() != "mycli2 sub <name>" {
+		t.Errorf("expected program name 'mycli2' but got '%s', '%s'", r.UseLine(), sub.UseLine())
+	}
+}
+
+// 测试使用方法中 flags 部分的标题显示未隐藏的 flags 数量
+func TestCommand_FlagCounts(t *testing.T) {
+	r := &Command{Use: "root"}
+	sub := &Command{Use: "sub", Run: func(cmd *Command, args []string) {}}
+	r.AddCommand(sub)
+	sub.LocalFlags().Bool("a", false, "a")
+	sub.LocalFlags().Bool("b", false, "b")
+	sub.LocalFlags().Bool("hidden", false, "hidden")
+	sub.LocalFlags().MarkHidden("hidden")
+	r.GlobalFlags().Bool("verbose", false, "verbose output")
+
+	var buf bytes.Buffer
+	templify(&buf, sub.UsageTemplate(), sub)
+	out := buf.String()
+	if !strings.Contains(out, "LocalFlags (2):") || !strings.Contains(out, "GlobalFlags (1):") {
+		t.Errorf("expected 2 local and 1 global flags in headings, but got:\n%s", out)
+	}
+}
+
+// 测试设置命令路径的分隔符
+func TestCommand_SetCommandPathSeparator(t *testing.T) {
+	r := &Command{Use: "root"}
+	s1 := &Command{Use: "test"}
+	s2 := &Command{Use: "subtest"}
+	r.AddCommand(s1)
+	s1.AddCommand(s2)
+
+	r.SetCommandPathSeparator(".")
+	expected := "root.test.subtest"
+	if p := s2.CommandPath(); p != expected {
+		t.Errorf("expected '%s', but got '%s'", expected, p)
+	}
+
+	// 使用方法中的命令行仍然以空格分隔
+	s2.Run = func(cmd *Command, args []string) {}
+	var buf bytes.Buffer
+	s1.renderUsage(&buf)
+	for _, line := range []string{"  root test [command]\n", `Use "root test [command] --help"`} {
+		if !strings.Contains(buf.String(), line) {
+			t.Errorf("expected usage to contain '%s', but got:\n%s", line, buf.String())
+		}
+	}
+	if useLine := s2.UseLine(); useLine != "root test subtest" {
+		t.Errorf("expected 'root test subtest', but got '%s'", useLine)
+	}
+}
+
+// 测试注入非终端的输入后 StdinIsPipe 返回 true
+func TestCommand_StdinIsPipe(t *testing.T) {
+	r := &Command{Use: "root"}
+	sub := &Command{Use: "sub"}
+	r.AddCommand(sub)
+
+	r.SetIn(strings.NewReader("piped data"))
+	if !sub.StdinIsPipe() {
+		t.Errorf("expected injected reader to be treated as a pipe")
+	}
+
+	pr, pw, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pr.Close()
+	defer pw.Close()
+	sub.SetIn(pr)
+	if !sub.StdinIsPipe() || sub.InOrStdin() != pr {
+		t.Errorf("expected os.Pipe reader to be treated as a pipe")
+	}
+}
+
+// 测试废弃的命令执行时输出废弃信息，且不出现在可用的子命令中
+func TestCommand_Deprecated(t *testing.T) {
+	r := &Command{Use: "root"}
+	old := &Command{Use: "old", Deprecated: "use 'new' instead", Run: func(cmd *Command, args []string) {}}
+	r.AddCommand(old)
+
+	if r.HasAvailableSubCmds() {
+		t.Errorf("expected deprecated command to be unavailable")
+	}
+	out := captureStdout(t, func() {
+		old.execute([]string{})
+	})
+	expected := "Command \"old\" is deprecated, use 'new' instead\n"
+	if out != expected {
+		t.Errorf("expected '%s' but got '%s'", expected, out)
+	}
+}
+
+// 测试切换 Enabled 后命令的可见性和能否被找到随之改变
+func TestCommand_Enabled(t *testing.T) {
+	enabled := false
+	r := &Command{Use: "root"}
+	beta := &Command{
+		Use:     "beta",
+		Run:     func(cmd *Command, args []string) {},
+		Enabled: func() bool { return enabled },
+	}
+	r.AddCommand(beta)
+
+	_, _, err := r.Find([]string{"root", "beta"})
+	if err != (CommandNotAvailable{Name: "root beta"}) || r.HasAvailableSubCmds() {
+		t.Errorf("expected disabled command to be hidden and unavailable, but got '%v'", err)
+	}
+
+	enabled = true
+	cmd, _, err := r.Find([]string{"root", "beta"})
+	if err != nil || cmd != beta || !r.HasAvailableSubCmds() {
+		t.Errorf("expected enabled command to be found and visible, but got '%v'", err)
+	}
+}
+
+// 测试 RunE 优先于 Run 执行，且它返回的错误由 Execute 返回
+func TestCommand_RunE(t *testing.T) {
+	expected := errors.New("failed")
+	ranRun := false
+	r := &Command{Use: "root"}
+	sub := &Command{
+		Use: "sub",
+		Run: func(cmd *Command, args []string) {
+			ranRun = true
+		},
+		RunE: func(cmd *Command, args []string) error {
+			return expected
+		},
+	}
+	r.AddCommand(sub)
+
+	os.Args = []string{"root", "sub"}
+	if err := r.Execute(); err != expected || ranRun {
+		t.Errorf("expected error '%v' without running Run, but got '%v', ran '%v'", expected, err, ranRun)
+	}
+}
+
+// 测试 ExecuteContext 的上下文传递给要执行的子命令
+func TestCommand_ExecuteContext(t *testing.T) {
+	type key struct{}
+	var got context.Context
+	r := &Command{Use: "root"}
+	sub := &Command{
+		Use: "sub",
+		RunE: func(cmd *Command, args []string) error {
+			got = cmd.Context()
+			return cmd.Context().Err()
+		},
+	}
+	r.AddCommand(sub)
+
+	ctx, cancel := context.WithCancel(context.WithValue(context.Background(), key{}, "value"))
+	os.Args = []string{"root", "sub"}
+	if err := r.ExecuteContext(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got.Value(key{}) != "value" {
+		t.Errorf("expected context of ExecuteContext but got '%v'", got)
+	}
+
+	cancel()
+	if err := r.ExecuteContext(ctx); err != context.Canceled {
+		t.Errorf("expected '%v' but got '%v'", context.Canceled, err)
+	}
+
+	if (&Command{Use: "other"}).Context() == nil {
+		t.Errorf("expected background context but got nil")
+	}
+}
+
+// 测试 SetArgs 设置的参数列表优先于 os.Args
+func TestCommand_SetArgs(t *testing.T) {
+	var got []string
+	r := &Command{Use: "root"}
+	sub := &Command{
+		Use: "sub",
+		Run: func(cmd *Command, args []string) {
+			count, _ := cmd.Flags().GetInt("count")
+			got = append(got, cmd.Name(), fmt.Sprint(count))
+		},
+	}
+	sub.LocalFlags().Int("count", 0, "count")
+	r.AddCommand(sub)
+
+	os.Args = []string{"root"}
+	r.SetArgs([]string{"sub", "--count", "3"})
+	if err := r.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"sub", "3"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected '%q' but got '%q'", expected, got)
+	}
+	expectedArgs := []string{"sub", "--count", "3"}
+	if !reflect.DeepEqual(sub.InvocationArgs(), expectedArgs) {
+		t.Errorf("expected '%q' but got '%q'", expectedArgs, sub.InvocationArgs())
+	}
+}
+
+// 测试设置 RecoverPanics 后 panic 被恢复为 PanicError 并调用 panic 处理函数
+func TestCommand_RecoverPanics(t *testing.T) {
+	var handled *Command
+	r := &Command{Use: "root", RecoverPanics: true}
+	sub := &Command{
+		Use: "sub",
+		Run: func(cmd *Command, args []string) { panic("boom") },
+	}
+	r.AddCommand(sub)
+	r.SetPanicHandler(func(cmd *Command, recovered interface{}) { handled = cmd })
+
+	err := r.ExecuteLine("sub")
+	var panicErr PanicError
+	if !errors.As(err, &panicErr) || panicErr.Command != "root sub" || panicErr.Value != "boom" {
+		t.Errorf("expected PanicError for 'root sub' but got '%v'", err)
+	}
+	if handled != sub {
+		t.Errorf("expected panic handler to be called with 'sub'")
+	}
+}
+
+// 测试恢复 panic 时仍然调用 PersistentPostRun 以及 OnShutdown 注册的清理函数
+func TestCommand_RecoverPanicsRunsCleanup(t *testing.T) {
+	calls := []string{}
+	r := &Command{
+		Use:               "root",
+		RecoverPanics:     true,
+		PersistentPreRun:  func(cmd *Command, args []string) { calls = append(calls, "pre") },
+		PersistentPostRun: func(cmd *Command, args []string) { calls = append(calls, "post") },
+	}
+	sub := &Command{
+		Use:           "sub",
+		PostRunAlways: func(cmd *Command, args []string) { calls = append(calls, "always") },
+		Run: func(cmd *Command, args []string) {
+			cmd.OnShutdown(func() { calls = append(calls, "shutdown") })
+			panic("boom")
+		},
+	}
+	r.AddCommand(sub)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	r.SetArgs([]string{"sub"})
+	if err := r.ExecuteContext(ctx); !errors.As(err, &PanicError{}) {
+		t.Errorf("expected PanicError but got '%v'", err)
+	}
+	if expected := []string{"pre", "post", "always", "shutdown"}; !reflect.DeepEqual(calls, expected) {
+		t.Errorf("expected %q but got %q", expected, calls)
+	}
+}
+
+// 测试 PreParse 返回的参数列表替代原来的参数列表被解析
+func TestCommand_PreParse(t *testing.T) {
+	c := &Command{
+		Use: "build",
+		PreParse: func(cmd *Command, args []string) []string {
+			expanded := []string{"--target", "linux"}
+			for _, arg := range args {
+				if arg == "-R" {
+					expanded = append(expanded, "--release")
+				} else {
+					expanded = append(expanded, arg)
+				}
+			}
+			return expanded
+		},
+	}
+	c.LocalFlags().String("target", "", "target platform")
+	c.LocalFlags().Bool("release", false, "release build")
+
+	if err := c.ParseFlags([]string{"-R"}); err != nil {
+		t.Fatal(err)
+	}
+	target, _ := c.Flags().GetString("target")
+	release, _ := c.Flags().GetBool("release")
+	if target != "linux" || !release {
+		t.Errorf("expected 'linux', 'true' but got '%s', '%v'", target, release)
+	}
+}
+
+// 测试 ExecuteC 返回实际执行的命令
+func TestCommand_ExecuteC(t *testing.T) {
+	r := &Command{Use: "root"}
+	service := &Command{Use: "service"}
+	start := &Command{Use: "start", Run: func(cmd *Command, args []string) {}}
+	r.AddCommand(service)
+	service.AddCommand(start)
+
+	r.SetArgs([]string{"service", "start"})
+	executed, err := r.ExecuteC()
+	if err != nil || executed != start {
+		t.Errorf("expected 'start' without error but got '%v', error '%v'", executed, err)
+	}
+}
+
+// 测试 ExecuteBatch 依次执行每组参数，返回的错误与参数的下标对应，且 flags 不会在两次执行之间残留
+func TestCommand_ExecuteBatch(t *testing.T) {
+	names := []string{}
+	r := &Command{Use: "root"}
+	greet := &Command{
+		Use:          "greet",
+		SilenceUsage: true,
+		Run: func(cmd *Command, args []string) {
+			name, _ := cmd.Flags().GetString("name")
+			names = append(names, name)
+		},
+	}
+	greet.LocalFlags().String("name", "nobody", "who to greet")
+	r.AddCommand(greet)
+
+	errs := r.ExecuteBatch([][]string{
+		{"greet", "--name", "bob"},
+		{"greet", "--port", "80"},
+		{"greet"},
+	})
+	if len(errs) != 3 || errs[0] != nil || errs[1] == nil || errs[2] != nil {
+		t.Errorf("expected only the second invocation to fail, but got %v", errs)
+	}
+	if !reflect.DeepEqual(names, []string{"bob", "nobody"}) {
+		t.Errorf("expected '[bob nobody]' but got '%q'", names)
+	}
+}
+
+// 测试 Reset 之后同一个命令树可以再次执行，且上一次指定的 flags 不再生效
+func TestCommand_Reset(t *testing.T) {
+	var verbose bool
+	var tags []string
+	r := &Command{Use: "root"}
+	sub := &Command{
+		Use: "sub",
+		Run: func(cmd *Command, args []string) {
+			verbose, _ = cmd.Flags().GetBool("verbose")
+			tags, _ = cmd.Flags().GetStringSlice("tag")
+		},
+	}
+	r.GlobalFlags().Bool("verbose", false, "verbose output")
+	sub.LocalFlags().StringSlice("tag", []string{"a"}, "tags")
+	r.AddCommand(sub)
+
+	if err := r.ExecuteLine("sub --verbose --tag b"); err != nil || !verbose {
+		t.Fatalf("expected verbose without error, but got '%v', error '%v'", verbose, err)
+	}
+	r.Reset()
+	if sub.Flags().Changed("verbose") || sub.Flags().Changed("tag") || sub.InvocationArgs() != nil {
+		t.Errorf("expected flags and invocation args to be reset")
+	}
+	if err := r.ExecuteLine("sub"); err != nil {
+		t.Fatal(err)
+	}
+	if verbose || !reflect.DeepEqual(tags, []string{"a"}) {
+		t.Errorf("expected 'false', '[a]' but got '%v', '%q'", verbose, tags)
+	}
+
+	// Reset 之后再次指定的切片 flag 替换默认值，而不是追加到默认值之后
+	for _, line := range []string{"sub --tag b", "sub --tag c --tag d"} {
+		r.Reset()
+		if err := r.ExecuteLine(line); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !reflect.DeepEqual(tags, []string{"c", "d"}) {
+		t.Errorf("expected '[c d]' but got '%q'", tags)
+	}
+	r.Reset()
+	if err := r.ExecuteLine("sub --tag c"); err != nil || !reflect.DeepEqual(tags, []string{"c"}) {
+		t.Errorf("expected '[c]' but got '%q', error '%v'", tags, err)
+	}
+}
@@ -0,0 +1,348 @@
+package bobra
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	flag "github.com/spf13/pflag"
+)
+
+// 告诉 shell 如何处理补全结果的指令，可以按位组合
+type ShellCompDirective int
+
+const (
+	// 补全出错，shell 应当忽略补全结果
+	ShellCompDirectiveError ShellCompDirective = 1 << iota
+	// 补全后不在末尾添加空格
+	ShellCompDirectiveNoSpace
+	// 没有补全结果时不使用文件名补全
+	ShellCompDirectiveNoFileComp
+	// 补全结果为文件扩展名，只补全具有这些扩展名的文件
+	ShellCompDirectiveFilterFileExt
+	// 只补全目录名
+	ShellCompDirectiveFilterDirs
+
+	// 使用 shell 默认的补全行为
+	ShellCompDirectiveDefault ShellCompDirective = 0
+)
+
+// 返回补全第 len(args)+1 个位置参数的候选值。设置了 ValidArgs 时返回其中以 toComplete 开头的取值，
+// 否则调用 ValidArgsFunction，都没有设置时使用 shell 默认的补全行为。已经在 args 中出现的取值不会再次返回
+func (c *Command) CompleteArgs(args []string, toComplete string) ([]string, ShellCompDirective) {
+	if c.ValidArgs != nil {
+		return FilterCompletions(filterPrefix(c.ValidArgs, toComplete), args), ShellCompDirectiveNoFileComp
+	}
+	if c.ValidArgsFunction != nil {
+		candidates, directive := c.ValidArgsFunction(c, args, toComplete)
+		return FilterCompletions(candidates, args), directive
+	}
+	return nil, ShellCompDirectiveDefault
+}
+
+// 返回 candidates 中不在 alreadyChosen 中的候选值，"取值\t说明" 形式的候选值只比较取值部分
+func FilterCompletions(candidates, alreadyChosen []string) []string {
+	if len(alreadyChosen) == 0 {
+		return candidates
+	}
+	filtered := []string{}
+	for _, candidate := range candidates {
+		if !stringInSlice(strings.SplitN(candidate, "\t", 2)[0], alreadyChosen) {
+			filtered = append(filtered, candidate)
+		}
+	}
+	return filtered
+}
+
+// 标记 flag 的取值为文件名的标注，值为允许的文件扩展名
+const FlagFilenameAnnotation = "bobra_annotation_filename_extensions"
+
+// 标记 flag 的取值为目录名的标注
+const FlagDirnameAnnotation = "bobra_annotation_dirname"
+
+// 将名为 name 的 flag 标记为文件名，补全时只补全具有 extensions 中扩展名的文件，没有指定扩展名时补全全部文件
+func (c *Command) MarkFlagFilename(name string, extensions ...string) error {
+	if err := c.Flags().SetAnnotation(name, FlagFilenameAnnotation, extensions); err != nil {
+		return ObjectNotFound{Type: "Flag", Name: name}
+	}
+	return nil
+}
+
+// 将名为 name 的 flag 标记为目录名，补全时只补全目录
+func (c *Command) MarkFlagDirname(name string) error {
+	if err := c.Flags().SetAnnotation(name, FlagDirnameAnnotation, []string{"true"}); err != nil {
+		return ObjectNotFound{Type: "Flag", Name: name}
+	}
+	return nil
+}
+
+// 补全 flag 取值的函数，args 为已经输入的位置参数
+type FlagCompletionFunc func(cmd *Command, args []string, toComplete string) ([]string, ShellCompDirective)
+
+// 为名为 name 的 flag 注册补全取值的函数，用于补全需要动态获取的取值。为父命令的全局 flag 注册的函数对子命令同样有效
+func (c *Command) RegisterFlagCompletionFunc(name string, fn FlagCompletionFunc) error {
+	if c.Flags().Lookup(name) == nil {
+		return ObjectNotFound{Type: "Flag", Name: name}
+	}
+	if c.flagCompletionFuncs == nil {
+		c.flagCompletionFuncs = map[string]FlagCompletionFunc{}
+	}
+	c.flagCompletionFuncs[name] = fn
+	return nil
+}
+
+// 返回补全名为 name 的 flag 的取值的候选值。优先调用 RegisterFlagCompletionFunc 为该 flag 注册的函数，
+// 通过 Enum 注册的 flag 返回可选取值中以 toComplete 开头的取值，取值附带说明时以 "取值\t说明" 的形式返回，
+// 标记为文件名的 flag 返回允许的扩展名，标记为目录名的 flag 只补全目录，其他 flags 使用 shell 默认的补全行为
+func (c *Command) CompleteFlag(name string, args []string, toComplete string) ([]string, ShellCompDirective) {
+	f := c.Flags().Lookup(name)
+	if f == nil {
+		return nil, ShellCompDirectiveError
+	}
+	for p := c; p != nil; p = p.parent {
+		if fn, ok := p.flagCompletionFuncs[name]; ok {
+			return fn(c, args, toComplete)
+		}
+	}
+	if _, ok := f.Annotations[FlagEnumAnnotation]; ok {
+		return enumCandidates(f, toComplete), ShellCompDirectiveNoFileComp
+	}
+	if extensions, ok := f.Annotations[FlagFilenameAnnotation]; ok && len(extensions) > 0 {
+		return extensions, ShellCompDirectiveFilterFileExt
+	}
+	if _, ok := f.Annotations[FlagDirnameAnnotation]; ok {
+		return nil, ShellCompDirectiveFilterDirs
+	}
+	return nil, ShellCompDirectiveDefault
+}
+
+// 补全协议使用的隐藏子命令。shell 以 "程序 __complete 已输入的参数... 正在输入的参数" 的形式调用程序，
+// 程序每行输出一个候选值，候选值可以以 "\t说明" 结尾，最后一行为 ":" 加上 ShellCompDirective 的值
+const ShellCompRequestCmd = "__complete"
+
+// 按照补全协议将在 args 之后补全最后一个参数的候选值输出到 w
+func (c *Command) complete(w io.Writer, args []string) error {
+	toComplete := ""
+	if len(args) > 0 {
+		toComplete, args = args[len(args)-1], args[:len(args)-1]
+	}
+	candidates, directive := c.completions(args, toComplete)
+	for _, candidate := range candidates {
+		fmt.Fprintln(w, candidate)
+	}
+	_, err := fmt.Fprintf(w, ":%d\n", directive)
+	return err
+}
+
+// 返回在 args 之后补全 toComplete 的候选值：正在输入 flag 的取值时调用 CompleteFlag，以 "-" 开头时补全 flag 名称，
+// 否则补全子命令名称，没有匹配的子命令时调用 CompleteArgs。已经输入了 help 时补全要查看使用方法的命令路径
+func (c *Command) completions(args []string, toComplete string) ([]string, ShellCompDirective) {
+	cmd, rest, err := c.Find(append([]string{c.Name()}, args...))
+	if err == FoundHelp {
+		return cmd.helpCompletions(args, toComplete)
+	}
+	if err != nil {
+		return nil, ShellCompDirectiveError
+	}
+	positional := stripFlags(rest, cmd)
+
+	// --name=value 形式的 flag 取值，候选值需要带上 "--name=" 前缀
+	if i := strings.Index(toComplete, "="); i > 0 && strings.HasPrefix(toComplete, "-") {
+		f := cmd.flagForArg(toComplete[:i])
+		if f == nil {
+			return nil, ShellCompDirectiveError
+		}
+		candidates, directive := cmd.CompleteFlag(f.Name, positional, toComplete[i+1:])
+		if directive&(ShellCompDirectiveFilterFileExt|ShellCompDirectiveFilterDirs) == 0 {
+			for j := range candidates {
+				candidates[j] = toComplete[:i+1] + candidates[j]
+			}
+		}
+		return candidates, directive
+	}
+	if len(rest) > 0 {
+		if f := cmd.flagForArg(rest[len(rest)-1]); f != nil && f.NoOptDefVal == "" {
+			return cmd.CompleteFlag(f.Name, positional, toComplete)
+		}
+	}
+
+	if strings.HasPrefix(toComplete, "-") {
+		return cmd.flagNameCandidates(toComplete)
+	}
+	if len(positional) == 0 && cmd.HasSubCommands() {
+		candidates := cmd.subCommandCandidates(toComplete)
+		if len(candidates) > 0 || cmd.argsValidator() == nil {
+			return candidates, ShellCompDirectiveNoFileComp
+		}
+	}
+	return cmd.CompleteArgs(positional, toComplete)
+}
+
+// 返回名称以 prefix 开头的 flags。bool 等不需要取值的 flag 是完整的参数，需要取值的 flag 以 "--name=" 的形式返回，
+// 只有一个候选值且需要取值时不在末尾添加空格，以便继续输入取值
+func (c *Command) flagNameCandidates(prefix string) ([]string, ShellCompDirective) {
+	candidates := []string{}
+	directive := ShellCompDirectiveNoFileComp
+	c.Flags().VisitAll(func(f *flag.Flag) {
+		name := "--" + f.Name
+		if f.Hidden || f.Deprecated != "" || !strings.HasPrefix(name, prefix) {
+			return
+		}
+		if f.Value.Type() != "bool" && f.NoOptDefVal == "" {
+			name += "="
+		}
+		candidates = append(candidates, withDescription(name, f.Usage))
+	})
+	if len(candidates) == 1 && strings.HasSuffix(strings.SplitN(candidates[0], "\t", 2)[0], "=") {
+		directive |= ShellCompDirectiveNoSpace
+	}
+	return candidates, directive
+}
+
+// 返回 "help" 之后补全 toComplete 的候选值，即 help 之后已经输入的命令路径下可用的子命令，隐藏的命令不会出现
+func (c *Command) helpCompletions(args []string, toComplete string) ([]string, ShellCompDirective) {
+	words := stripFlags(args, c)
+	i := 0
+	for i < len(words) && words[i] != "help" {
+		i++
+	}
+	target := c
+	for i++; i < len(words); i++ {
+		if target = target.findSubCmd(words[i]); target == nil {
+			return nil, ShellCompDirectiveNoFileComp
+		}
+	}
+	return target.subCommandCandidates(toComplete), ShellCompDirectiveNoFileComp
+}
+
+// 返回名称以 prefix 开头的可用子命令，附带子命令的简短介绍
+func (c *Command) subCommandCandidates(prefix string) []string {
+	candidates := []string{}
+	for _, sub := range c.commands {
+		if sub.IsAvailable() && strings.HasPrefix(sub.Name(), prefix) {
+			candidates = append(candidates, withDescription(sub.Name(), sub.Short))
+		}
+	}
+	return candidates
+}
+
+// 返回参数 arg 表示的 flag，arg 为 --name 或 -n 的形式，不存在时返回 nil
+func (c *Command) flagForArg(arg string) *flag.Flag {
+	switch {
+	case strings.HasPrefix(arg, "--"):
+		return c.Flags().Lookup(arg[2:])
+	case strings.HasPrefix(arg, "-") && len(arg) == 2:
+		return c.Flags().ShorthandLookup(arg[1:])
+	}
+	return nil
+}
+
+// 返回附带说明的候选值，说明只保留第一行
+func withDescription(candidate string, description string) string {
+	if description = strings.SplitN(description, "\n", 2)[0]; description == "" {
+		return candidate
+	}
+	return candidate + "\t" + description
+}
+
+// 返回 values 中以 prefix 开头的取值
+func filterPrefix(values []string, prefix string) []string {
+	candidates := []string{}
+	for _, value := range values {
+		if strings.HasPrefix(value, prefix) {
+			candidates = append(candidates, value)
+		}
+	}
+	return candidates
+}
+
+// 补全规格中命令的描述
+type commandSpec struct {
+	Name        string        `json:"name"`
+	Path        string        `json:"path"`
+	Args        []argSpec     `json:"args"`
+	Flags       []flagSpec    `json:"flags"`
+	Subcommands []commandSpec `json:"subcommands,omitempty"`
+}
+
+// 补全规格中位置参数的描述
+type argSpec struct {
+	Name        string   `json:"name"`
+	Placeholder string   `json:"placeholder"`
+	Required    bool     `json:"required"`
+	Variadic    bool     `json:"variadic"`
+	Values      []string `json:"values,omitempty"`
+	Dynamic     bool     `json:"dynamic,omitempty"`
+}
+
+// 补全规格中 flag 的描述，Source 为补全取值的方式：values、filename、dirname、function，为空时使用默认的补全行为，
+// Descriptions 为与 Values 一一对应的取值说明
+type flagSpec struct {
+	Name         string   `json:"name"`
+	Shorthand    string   `json:"shorthand,omitempty"`
+	Type         string   `json:"type"`
+	Source       string   `json:"source,omitempty"`
+	Values       []string `json:"values,omitempty"`
+	Descriptions []string `json:"descriptions,omitempty"`
+	Extensions   []string `json:"extensions,omitempty"`
+}
+
+// 将命令及其可用的子命令的补全规格以 JSON 格式输出到 w，供编辑器等工具使用。规格中包含 Use 中声明的位置参数
+// 及其可选取值，以及 flags 的类型和补全取值的方式。Use 中没有声明位置参数但设置了 ValidArgs 或
+// ValidArgsFunction 时，位置参数以可以重复出现的 [arg]... 描述
+func (c *Command) GenCompletionSpec(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(c.completionSpec())
+}
+
+// 返回命令及其可用的子命令的补全规格
+func (c *Command) completionSpec() commandSpec {
+	spec := commandSpec{Name: c.Name(), Path: c.CommandPath(), Args: []argSpec{}, Flags: []flagSpec{}}
+	declared := c.useArgs()
+	if len(declared) == 0 && (c.ValidArgs != nil || c.ValidArgsFunction != nil) {
+		declared = []useArg{{name: "arg", placeholder: "[arg]...", optional: true, variadic: true}}
+	}
+	for _, arg := range declared {
+		spec.Args = append(spec.Args, argSpec{
+			Name:        arg.name,
+			Placeholder: arg.placeholder,
+			Required:    !arg.optional,
+			Variadic:    arg.variadic,
+			Values:      c.ValidArgs,
+			Dynamic:     c.ValidArgsFunction != nil,
+		})
+	}
+	c.Flags().VisitAll(func(f *flag.Flag) {
+		if !f.Hidden {
+			spec.Flags = append(spec.Flags, c.flagSpec(f))
+		}
+	})
+	for _, sub := range c.commands {
+		if sub.IsAvailable() {
+			spec.Subcommands = append(spec.Subcommands, sub.completionSpec())
+		}
+	}
+	return spec
+}
+
+// 返回 flag 的补全规格，补全取值的方式与 CompleteFlag 的优先级相同
+func (c *Command) flagSpec(f *flag.Flag) flagSpec {
+	spec := flagSpec{Name: f.Name, Shorthand: f.Shorthand, Type: f.Value.Type()}
+	for p := c; p != nil; p = p.parent {
+		if _, ok := p.flagCompletionFuncs[f.Name]; ok {
+			spec.Source = "function"
+			return spec
+		}
+	}
+	if allowed, ok := f.Annotations[FlagEnumAnnotation]; ok {
+		spec.Source, spec.Values = "values", allowed
+		spec.Descriptions = f.Annotations[FlagEnumDescriptionAnnotation]
+	} else if extensions, ok := f.Annotations[FlagFilenameAnnotation]; ok {
+		spec.Source, spec.Extensions = "filename", extensions
+	} else if _, ok := f.Annotations[FlagDirnameAnnotation]; ok {
+		spec.Source = "dirname"
+	}
+	return spec
+}
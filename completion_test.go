@@ -0,0 +1,253 @@
+package bobra
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// 测试 CompleteArgs 根据 ValidArgs 或 ValidArgsFunction 返回位置参数的候选值
+func TestCommand_CompleteArgs(t *testing.T) {
+	c := &Command{Use: "service", ValidArgs: []string{"start", "status", "stop"}}
+	candidates, directive := c.CompleteArgs([]string{}, "st")
+	if !reflect.DeepEqual(candidates, []string{"start", "status", "stop"}) || directive != ShellCompDirectiveNoFileComp {
+		t.Errorf("expected all valid args but got '%q', %d", candidates, directive)
+	}
+	candidates, _ = c.CompleteArgs([]string{}, "sta")
+	if !reflect.DeepEqual(candidates, []string{"start", "status"}) {
+		t.Errorf("expected '[start status]' but got '%q'", candidates)
+	}
+
+	c = &Command{Use: "logs"}
+	if _, directive := c.CompleteArgs([]string{}, ""); directive != ShellCompDirectiveDefault {
+		t.Errorf("expected default directive but got %d", directive)
+	}
+	c.ValidArgsFunction = func(cmd *Command, args []string, toComplete string) ([]string, ShellCompDirective) {
+		return []string{"pod-" + toComplete}, ShellCompDirectiveNoSpace
+	}
+	candidates, directive = c.CompleteArgs([]string{}, "a")
+	if !reflect.DeepEqual(candidates, []string{"pod-a"}) || directive != ShellCompDirectiveNoSpace {
+		t.Errorf("expected '[pod-a]' but got '%q', %d", candidates, directive)
+	}
+}
+
+// 测试没有设置 ValidArgs 时 OnlyValidArgs 使用 ValidArgsFunction 的候选值校验位置参数
+func TestOnlyValidArgs_ValidArgsFunction(t *testing.T) {
+	c := &Command{
+		Use: "scale",
+		ValidArgsFunction: func(cmd *Command, args []string, toComplete string) ([]string, ShellCompDirective) {
+			if len(args) == 0 {
+				return []string{"web", "worker"}, ShellCompDirectiveNoFileComp
+			}
+			return []string{"1", "2", "3"}, ShellCompDirectiveNoFileComp
+		},
+	}
+	if err := OnlyValidArgs(c, []string{"web", "2"}); err != nil {
+		t.Errorf("expected no error but got '%v'", err)
+	}
+	if err := OnlyValidArgs(c, []string{"web", "web"}); err == nil || !strings.Contains(err.Error(), "valid arguments are: 1, 2, 3") {
+		t.Errorf("expected invalid argument error but got '%v'", err)
+	}
+}
+
+// 测试 RegisterFlagCompletionFunc 注册的函数用于补全 flag 的取值，并对子命令继承的全局 flag 有效
+func TestCommand_RegisterFlagCompletionFunc(t *testing.T) {
+	r := &Command{Use: "kubectl"}
+	r.PersistentFlags().String("namespace", "", "namespace of the resource")
+	sub := &Command{Use: "get", Run: func(cmd *Command, args []string) {}}
+	r.AddCommand(sub)
+
+	err := r.RegisterFlagCompletionFunc("namespace", func(cmd *Command, args []string, toComplete string) ([]string, ShellCompDirective) {
+		return filterPrefix([]string{"default", "dev-" + strings.Join(args, "-"), "kube-system"}, toComplete), ShellCompDirectiveNoFileComp
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.RegisterFlagCompletionFunc("missing", nil); err == nil {
+		t.Errorf("expected error for unknown flag")
+	}
+
+	candidates, directive := sub.CompleteFlag("namespace", []string{"pods"}, "d")
+	if !reflect.DeepEqual(candidates, []string{"default", "dev-pods"}) || directive != ShellCompDirectiveNoFileComp {
+		t.Errorf("expected '[default dev-pods]' but got '%q', %d", candidates, directive)
+	}
+}
+
+// 测试标记为文件名或目录名的 flag 补全时返回对应的指令
+func TestCommand_MarkFlagFilenameAndDirname(t *testing.T) {
+	c := &Command{Use: "apply", Run: func(cmd *Command, args []string) {}}
+	c.LocalFlags().String("file", "", "manifest file")
+	c.LocalFlags().String("log", "", "log file")
+	c.LocalFlags().String("output-dir", "", "output directory")
+	if err := c.MarkFlagFilename("file", "yaml", "json"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.MarkFlagFilename("log"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.MarkFlagDirname("output-dir"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.MarkFlagFilename("missing"); err == nil {
+		t.Errorf("expected error for unknown flag")
+	}
+	if err := c.MarkFlagDirname("missing"); err == nil {
+		t.Errorf("expected error for unknown flag")
+	}
+
+	candidates, directive := c.CompleteFlag("file", []string{}, "")
+	if !reflect.DeepEqual(candidates, []string{"yaml", "json"}) || directive != ShellCompDirectiveFilterFileExt {
+		t.Errorf("expected '[yaml json]' with file extension filter but got '%q', %d", candidates, directive)
+	}
+	if _, directive := c.CompleteFlag("log", []string{}, ""); directive != ShellCompDirectiveDefault {
+		t.Errorf("expected default directive for file without extensions but got %d", directive)
+	}
+	if _, directive := c.CompleteFlag("output-dir", []string{}, ""); directive != ShellCompDirectiveFilterDirs {
+		t.Errorf("expected directory filter but got %d", directive)
+	}
+}
+
+// 测试生成的补全规格中包含位置参数及其可选取值，以及 flags 补全取值的方式
+func TestCommand_GenCompletionSpec(t *testing.T) {
+	r := &Command{Use: "app"}
+	sub := &Command{Use: "deploy <env> [services]...", ValidArgs: []string{"staging", "production"}, Run: func(cmd *Command, args []string) {}}
+	Enum(sub.LocalFlags(), "strategy", "rolling", []string{"rolling", "recreate"}, "deploy strategy")
+	sub.LocalFlags().String("manifest", "", "manifest file")
+	sub.MarkFlagFilename("manifest", "yaml", "yml")
+	r.AddCommand(sub)
+
+	var buf bytes.Buffer
+	if err := r.GenCompletionSpec(&buf); err != nil {
+		t.Fatal(err)
+	}
+	var spec commandSpec
+	if err := json.Unmarshal(buf.Bytes(), &spec); err != nil {
+		t.Fatalf("expected valid JSON but got '%v':\n%s", err, buf.String())
+	}
+	if len(spec.Subcommands) != 1 || spec.Subcommands[0].Path != "app deploy" {
+		t.Fatalf("expected spec for 'app deploy' but got:\n%s", buf.String())
+	}
+	deploy := spec.Subcommands[0]
+	expectedArgs := []argSpec{
+		{Name: "env", Placeholder: "<env>", Required: true, Values: []string{"staging", "production"}},
+		{Name: "services", Placeholder: "[services]...", Variadic: true, Values: []string{"staging", "production"}},
+	}
+	if !reflect.DeepEqual(deploy.Args, expectedArgs) {
+		t.Errorf("expected args %+v but got %+v", expectedArgs, deploy.Args)
+	}
+	flags := map[string]flagSpec{}
+	for _, f := range deploy.Flags {
+		flags[f.Name] = f
+	}
+	if f := flags["strategy"]; f.Source != "values" || !reflect.DeepEqual(f.Values, []string{"rolling", "recreate"}) {
+		t.Errorf("expected enum values for '--strategy' but got %+v", f)
+	}
+	if f := flags["manifest"]; f.Source != "filename" || !reflect.DeepEqual(f.Extensions, []string{"yaml", "yml"}) {
+		t.Errorf("expected filename extensions for '--manifest' but got %+v", f)
+	}
+}
+
+// 测试通过 __complete 按照补全协议输出子命令、flag 名称以及 flag 取值的候选值
+func TestCommand_ShellCompRequestCmd(t *testing.T) {
+	r := &Command{Use: "kubectl"}
+	get := &Command{Use: "get <resource>", Short: "Display resources", ValidArgs: []string{"pods", "services"}, Run: func(cmd *Command, args []string) {}}
+	get.LocalFlags().StringP("namespace", "n", "", "target namespace")
+	get.LocalFlags().String("config", "", "config file")
+	get.LocalFlags().String("out-dir", "", "output directory")
+	get.RegisterFlagCompletionFunc("namespace", func(cmd *Command, args []string, toComplete string) ([]string, ShellCompDirective) {
+		return filterPrefix([]string{"default", "kube-system"}, toComplete), ShellCompDirectiveNoFileComp
+	})
+	get.MarkFlagFilename("config", "yaml")
+	get.MarkFlagDirname("out-dir")
+	r.AddCommand(get)
+
+	tests := []struct {
+		args     []string
+		expected string
+	}{
+		{[]string{"g"}, "get\tDisplay resources\n:4\n"},
+		{[]string{"get", "p"}, "pods\n:4\n"},
+		{[]string{"get", "--na"}, "--namespace=\ttarget namespace\n:6\n"},
+		{[]string{"get", "--namespace", "k"}, "kube-system\n:4\n"},
+		{[]string{"get", "pods", "-n", ""}, "default\nkube-system\n:4\n"},
+		{[]string{"get", "--namespace=d"}, "--namespace=default\n:4\n"},
+		{[]string{"get", "--config", ""}, "yaml\n:8\n"},
+		{[]string{"get", "--out-dir", ""}, ":16\n"},
+		{[]string{"missing", ""}, ":1\n"},
+	}
+	for _, test := range tests {
+		r.SetArgs(append([]string{ShellCompRequestCmd}, test.args...))
+		out := captureStdout(t, func() {
+			if err := r.Execute(); err != nil {
+				t.Errorf("unexpected error for '%q': %v", test.args, err)
+			}
+		})
+		if out != test.expected {
+			t.Errorf("expected %q for '%q' but got %q", test.expected, test.args, out)
+		}
+	}
+}
+
+// 测试输入 help 之后补全要查看使用方法的命令路径，隐藏的命令不会出现
+func TestCommand_CompleteHelpTargets(t *testing.T) {
+	r := &Command{Use: "mycli"}
+	service := &Command{Use: "service", Run: func(cmd *Command, args []string) {}}
+	service.AddCommand(&Command{Use: "start", Run: func(cmd *Command, args []string) {}})
+	r.AddCommand(service, &Command{Use: "server-debug", Hidden: true, Run: func(cmd *Command, args []string) {}})
+
+	tests := []struct {
+		args     []string
+		expected string
+	}{
+		{[]string{"help", "ser"}, "service\n:4\n"},
+		{[]string{"help", "service", ""}, "start\n:4\n"},
+		{[]string{"help", "missing", ""}, ":4\n"},
+	}
+	for _, test := range tests {
+		r.SetArgs(append([]string{ShellCompRequestCmd}, test.args...))
+		out := captureStdout(t, func() { r.Execute() })
+		if out != test.expected {
+			t.Errorf("expected %q for '%q' but got %q", test.expected, test.args, out)
+		}
+	}
+}
+
+// 测试补全 flag 名称时 bool flag 是完整的参数，需要取值的 flag 以 "=" 结尾并且不在末尾添加空格
+func TestCommand_CompleteFlagNames(t *testing.T) {
+	c := &Command{Use: "deploy", Run: func(cmd *Command, args []string) {}}
+	c.LocalFlags().Bool("force", false, "skip confirmation")
+	c.LocalFlags().String("env", "", "target environment")
+
+	tests := []struct {
+		args     []string
+		expected string
+	}{
+		{[]string{"--"}, "--env=\ttarget environment\n--force\tskip confirmation\n:4\n"},
+		{[]string{"--f"}, "--force\tskip confirmation\n:4\n"},
+		{[]string{"--e"}, "--env=\ttarget environment\n:6\n"},
+	}
+	for _, test := range tests {
+		c.SetArgs(append([]string{ShellCompRequestCmd}, test.args...))
+		out := captureStdout(t, func() { c.Execute() })
+		if out != test.expected {
+			t.Errorf("expected %q for '%q' but got %q", test.expected, test.args, out)
+		}
+	}
+}
+
+// 测试补全位置参数时不再返回已经输入的取值
+func TestFilterCompletions(t *testing.T) {
+	filtered := FilterCompletions([]string{"api\tAPI server", "web", "db"}, []string{"api", "db"})
+	if !reflect.DeepEqual(filtered, []string{"web"}) {
+		t.Errorf("expected '[web]' but got '%q'", filtered)
+	}
+
+	c := &Command{Use: "restart <service>...", ValidArgs: []string{"api", "web", "db", "worker"}, Run: func(cmd *Command, args []string) {}}
+	c.SetArgs([]string{ShellCompRequestCmd, "api", "web", ""})
+	out := captureStdout(t, func() { c.Execute() })
+	if expected := "db\nworker\n:4\n"; out != expected {
+		t.Errorf("expected %q but got %q", expected, out)
+	}
+}
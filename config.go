@@ -0,0 +1,113 @@
+package bobra
+
+import (
+	"bufio"
+	"os"
+	"strings"
+
+	flag "github.com/spf13/pflag"
+)
+
+// 配置文件参数的名称
+const configFlagName = "config"
+
+// 为根命令注册全局的 --config 参数，命令执行前会读取该配置文件，为命令行中未指定的 flags 设置默认值。
+// 默认路径的文件不存在时忽略，显式指定的文件不存在时返回错误
+func (c *Command) EnableConfigFlag(defaultPath string) {
+	root := c.Root()
+	root.PersistentFlags().String(configFlagName, defaultPath, "config file to seed flag defaults")
+	root.configEnabled = true
+}
+
+// 根据 flag 名称返回配置中的取值，ok 为 false 表示配置中没有该 flag
+type ConfigResolver func(name string) (value string, ok bool)
+
+// 为根命令设置配置的解析函数，配置文件中没有的 flags 会从 fn 中查找取值，fn 可以来自任意已加载的配置。
+// flag 的取值默认按以下优先级确定：命令行中指定的值 > 环境变量 > 配置文件 > fn 返回的值 > 默认值，
+// 其中命令行、环境变量、配置和默认值之间的顺序可以通过 SetValuePrecedence 修改
+func (c *Command) SetConfigResolver(fn ConfigResolver) {
+	c.Root().configResolver = fn
+}
+
+// 按顺序读取多个配置文件，后面的文件覆盖前面文件中相同的键，不存在的文件会被忽略。
+// 读取的值会在命令执行前作为命令行中未指定的 flags 的默认值
+func (c *Command) LoadConfigs(paths ...string) error {
+	values := map[string]string{}
+	for _, path := range paths {
+		v, err := readConfigFile(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		mergeConfig(values, v)
+	}
+
+	root := c.Root()
+	if root.configValues == nil {
+		root.configValues = map[string]string{}
+	}
+	mergeConfig(root.configValues, values)
+	return nil
+}
+
+// 返回 LoadConfigs 读取的值以及 --config 指定的配置文件中的值，作为命令 c 的 flags 来自配置的取值，
+// --config 指定的配置文件优先，配置文件中没有的 flags 再通过 SetConfigResolver 设置的函数查找
+func (c *Command) loadConfig() (map[string]string, error) {
+	root := c.Root()
+	values := map[string]string{}
+	mergeConfig(values, root.configValues)
+
+	if f := c.Flags().Lookup(configFlagName); root.configEnabled && f != nil && f.Value.String() != "" {
+		v, err := readConfigFile(f.Value.String())
+		if err != nil && !(os.IsNotExist(err) && !f.Changed) {
+			return nil, err
+		}
+		mergeConfig(values, v)
+	}
+	if root.configResolver != nil {
+		c.Flags().VisitAll(func(f *flag.Flag) {
+			if _, ok := values[f.Name]; ok {
+				return
+			}
+			if v, ok := root.configResolver(f.Name); ok {
+				values[f.Name] = v
+			}
+		})
+	}
+	delete(values, configFlagName)
+	return values, nil
+}
+
+// 将 src 中的值合并到 dst 中，覆盖相同的键
+func mergeConfig(dst, src map[string]string) {
+	for k, v := range src {
+		dst[k] = v
+	}
+}
+
+// 读取 "key: value" 或 "key = value" 格式的配置文件, 忽略空行和 '#' 开头的注释
+func readConfigFile(path string) (map[string]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	values := map[string]string{}
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		i := strings.IndexAny(text, ":=")
+		if i <= 0 {
+			return nil, InvalidConfig{Path: path, Line: line}
+		}
+		key := strings.TrimSpace(text[:i])
+		values[key] = strings.Trim(strings.TrimSpace(text[i+1:]), `"'`)
+	}
+	return values, scanner.Err()
+}
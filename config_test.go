@@ -0,0 +1,242 @@
+package bobra
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// 创建临时的配置文件
+func writeConfig(t *testing.T, dir, name, content string) string {
+	path := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// 测试 --config 指定的配置文件能够为子命令的 flags 设置默认值
+func TestCommand_EnableConfigFlag(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bobra")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := writeConfig(t, dir, "custom.yaml", "# greeting config\nname: from-config\n")
+
+	var got string
+	r := &Command{Use: "root"}
+	greet := &Command{
+		Use: "greet",
+		Run: func(cmd *Command, args []string) {
+			got, _ = cmd.Flags().GetString("name")
+		},
+	}
+	greet.LocalFlags().String("name", "nobody", "who to greet")
+	r.AddCommand(greet)
+	r.EnableConfigFlag(filepath.Join(dir, "default.yaml"))
+
+	// 默认配置文件不存在时忽略
+	os.Args = []string{"root", "greet"}
+	if err := r.Execute(); err != nil || got != "nobody" {
+		t.Errorf("expected 'nobody' without error, but got '%s', '%v'", got, err)
+	}
+
+	os.Args = []string{"root", "greet", "--config", path}
+	if err := r.Execute(); err != nil || got != "from-config" {
+		t.Errorf("expected 'from-config' without error, but got '%s', '%v'", got, err)
+	}
+
+	// 显式指定的配置文件不存在时报错
+	os.Args = []string{"root", "greet", "--config", filepath.Join(dir, "missing.yaml")}
+	if err := r.Execute(); err == nil {
+		t.Errorf("expected error for missing config file, but got nil")
+	}
+}
+
+// 测试配置文件的解析
+func Test_ReadConfigFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bobra")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	values, err := readConfigFile(writeConfig(t, dir, "ok.yaml", "a: 1\nb = \"two\"\n\n# c: 3\n"))
+	if err != nil || values["a"] != "1" || values["b"] != "two" || len(values) != 2 {
+		t.Errorf("unexpected config values '%v', error '%v'", values, err)
+	}
+	if _, err := readConfigFile(writeConfig(t, dir, "bad.yaml", "a: 1\nbroken\n")); err != (InvalidConfig{Path: filepath.Join(dir, "bad.yaml"), Line: 2}) {
+		t.Errorf("expected InvalidConfig at line 2, but got '%v'", err)
+	}
+}
+
+// 测试按顺序读取多个配置文件时后面的文件覆盖前面的文件
+func TestCommand_LoadConfigs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bobra")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	system := writeConfig(t, dir, "system.yaml", "region: us\nreplicas: 2\n")
+	user := writeConfig(t, dir, "user.yaml", "region: eu\n")
+
+	var region string
+	var replicas int
+	c := &Command{
+		Use: "deploy",
+		Run: func(cmd *Command, args []string) {
+			region, _ = cmd.Flags().GetString("region")
+			replicas, _ = cmd.Flags().GetInt("replicas")
+		},
+	}
+	c.LocalFlags().String("region", "", "deploy region")
+	c.LocalFlags().Int("replicas", 1, "number of replicas")
+
+	if err := c.LoadConfigs(system, filepath.Join(dir, "missing.yaml"), user); err != nil {
+		t.Fatalf("expected no error but got '%v'", err)
+	}
+	if err := c.execute([]string{}); err != nil || region != "eu" || replicas != 2 {
+		t.Errorf("expected 'eu', 2 but got '%s', %d, error '%v'", region, replicas, err)
+	}
+
+	bad := writeConfig(t, dir, "bad.yaml", "not a config line\n")
+	err = c.LoadConfigs(user, bad)
+	if err != (InvalidConfig{Path: bad, Line: 1}) {
+		t.Errorf("expected InvalidConfig for '%s' but got '%v'", bad, err)
+	}
+}
+
+// 测试 flag 取值的优先级：命令行 > 环境变量 > 配置文件 > SetConfigResolver > 默认值
+func TestCommand_SetConfigResolver(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bobra")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := writeConfig(t, dir, "app.yaml", "region: eu\nzone: a\ntag: x\nlabel: l1,l2\n")
+
+	values := map[string]string{}
+	sources := map[string]FlagSource{}
+	names := []string{"region", "zone", "tier", "replicas", "timeout", "tag", "label"}
+	c := &Command{
+		Use: "deploy",
+		Run: func(cmd *Command, args []string) {
+			for _, name := range names {
+				values[name] = cmd.Flags().Lookup(name).Value.String()
+				sources[name] = cmd.FlagSource(name)
+			}
+		},
+	}
+	c.LocalFlags().String("region", "us", "deploy region")
+	c.LocalFlags().String("zone", "b", "deploy zone")
+	c.LocalFlags().String("tier", "free", "service tier")
+	c.LocalFlags().Int("replicas", 1, "number of replicas")
+	c.LocalFlags().Int("timeout", 30, "timeout in seconds")
+	c.LocalFlags().StringSlice("tag", []string{"a"}, "image tags")
+	c.LocalFlags().StringSlice("label", []string{"l0"}, "labels")
+	if err := c.LoadConfigs(path); err != nil {
+		t.Fatal(err)
+	}
+	loaded := map[string]string{"region": "ap", "zone": "c", "tier": "pro", "replicas": "3"}
+	c.SetConfigResolver(func(name string) (string, bool) {
+		v, ok := loaded[name]
+		return v, ok
+	})
+	c.BindFlagsToEnv("deploy")
+	os.Setenv("DEPLOY_REPLICAS", "5")
+	os.Setenv("DEPLOY_ZONE", "d")
+	os.Setenv("DEPLOY_TAG", "y")
+	defer os.Unsetenv("DEPLOY_TAG")
+	defer os.Unsetenv("DEPLOY_REPLICAS")
+	defer os.Unsetenv("DEPLOY_ZONE")
+
+	if err := c.ExecuteLine("--replicas 7"); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string][2]string{
+		"replicas": {"7", string(FlagSourceFlag)},
+		"zone":     {"d", string(FlagSourceEnv)},
+		"region":   {"eu", string(FlagSourceConfig)},
+		"tier":     {"pro", string(FlagSourceConfig)},
+		"timeout":  {"30", string(FlagSourceDefault)},
+		"tag":      {"[y]", string(FlagSourceEnv)},
+		"label":    {"[l1,l2]", string(FlagSourceConfig)},
+	}
+	for name, e := range expected {
+		if values[name] != e[0] || string(sources[name]) != e[1] {
+			t.Errorf("expected '--%s' to be '%s' from %s but got '%s' from %s", name, e[0], e[1], values[name], sources[name])
+		}
+	}
+}
+
+// 测试 SetValuePrecedence 调换环境变量和配置的优先级后，取值的来源随之改变
+func TestCommand_SetValuePrecedence(t *testing.T) {
+	var region string
+	var tags []string
+	c := &Command{
+		Use: "deploy",
+		Run: func(cmd *Command, args []string) {
+			region, _ = cmd.Flags().GetString("region")
+			tags, _ = cmd.Flags().GetStringSlice("tag")
+		},
+	}
+	c.LocalFlags().String("region", "us", "deploy region")
+	c.LocalFlags().StringSlice("tag", []string{"a"}, "image tags")
+	c.SetConfigResolver(func(name string) (string, bool) {
+		v, ok := map[string]string{"region": "eu", "tag": "x"}[name]
+		return v, ok
+	})
+	c.BindFlagsToEnv("deploy")
+	os.Setenv("DEPLOY_REGION", "ap")
+	os.Setenv("DEPLOY_TAG", "y")
+	defer os.Unsetenv("DEPLOY_REGION")
+	defer os.Unsetenv("DEPLOY_TAG")
+
+	if err := c.ExecuteLine(""); err != nil || region != "ap" || !reflect.DeepEqual(tags, []string{"y"}) {
+		t.Errorf("expected env to win by default, but got '%s', '%q', error '%v'", region, tags, err)
+	}
+
+	if err := c.SetValuePrecedence([]string{"flag", "config", "env", "default"}); err != nil {
+		t.Fatal(err)
+	}
+	c.Reset()
+	if err := c.ExecuteLine(""); err != nil || region != "eu" || !reflect.DeepEqual(tags, []string{"x"}) {
+		t.Errorf("expected config to win, but got '%s', '%q', error '%v'", region, tags, err)
+	}
+	if source := c.FlagSource("region"); source != FlagSourceConfig {
+		t.Errorf("expected source 'config' but got '%s'", source)
+	}
+
+	if err := c.SetValuePrecedence([]string{"env", "config", "default", "flag"}); err != nil {
+		t.Fatal(err)
+	}
+	c.Reset()
+	if err := c.ExecuteLine("--region cn --tag z"); err != nil || region != "ap" || !reflect.DeepEqual(tags, []string{"y"}) {
+		t.Errorf("expected env to win over the command line, but got '%s', '%q', error '%v'", region, tags, err)
+	}
+
+	if err := c.SetValuePrecedence([]string{"default", "flag", "env", "config"}); err != nil {
+		t.Fatal(err)
+	}
+	c.Reset()
+	if err := c.ExecuteLine("--region cn --tag z"); err != nil || region != "us" || !reflect.DeepEqual(tags, []string{"a"}) {
+		t.Errorf("expected default to win over the command line, but got '%s', '%q', error '%v'", region, tags, err)
+	}
+	if c.Flags().Changed("region") || c.Flags().Changed("tag") || c.FlagSource("region") != FlagSourceDefault {
+		t.Errorf("expected flags restored to default to be unchanged with source 'default'")
+	}
+
+	for _, order := range [][]string{
+		{"flag", "env", "config"},
+		{"flag", "env", "env", "default"},
+		{"flag", "env", "config", "default", "file"},
+		{"flag", "env", "config", "defaults"},
+	} {
+		if err := c.SetValuePrecedence(order); err == nil {
+			t.Errorf("expected error for invalid precedence %q", order)
+		}
+	}
+}
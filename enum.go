@@ -0,0 +1,89 @@
+package bobra
+
+import (
+	"fmt"
+	"strings"
+
+	flag "github.com/spf13/pflag"
+)
+
+// 记录 flag 可选取值的标注，用于补全 flag 的取值
+const FlagEnumAnnotation = "bobra_annotation_enum_values"
+
+// 记录 flag 每个可选取值的说明的标注，与 FlagEnumAnnotation 中的取值一一对应
+const FlagEnumDescriptionAnnotation = "bobra_annotation_enum_descriptions"
+
+// 只能取 allowed 中的值的字符串 flag
+type enumValue struct {
+	value   *string
+	allowed []string
+}
+
+func (e *enumValue) String() string {
+	return *e.value
+}
+
+func (e *enumValue) Set(v string) error {
+	if !stringInSlice(v, e.allowed) {
+		return fmt.Errorf("must be one of: %s", strings.Join(e.allowed, ", "))
+	}
+	*e.value = v
+	return nil
+}
+
+// 与普通的字符串 flag 相同，使得 GetString 可以读取它的取值
+func (e *enumValue) Type() string {
+	return "string"
+}
+
+// 在 fs 中注册只能取 allowed 中的值的字符串 flag，解析时校验取值，使用方法中列出可选的取值，补全时返回可选的取值。
+// allowed 中的取值可以写成 "debug#verbose output" 的形式附带说明，补全时说明跟随取值一起返回
+func Enum(fs *flag.FlagSet, name string, value string, allowed []string, usage string) *string {
+	return EnumP(fs, name, "", value, allowed, usage)
+}
+
+// 与 Enum 相同，并且可以指定单字母的缩写
+func EnumP(fs *flag.FlagSet, name string, shorthand string, value string, allowed []string, usage string) *string {
+	p := new(string)
+	*p = value
+	values, descriptions := splitEnumDescriptions(allowed)
+	usage = fmt.Sprintf("%s (one of: %s)", usage, strings.Join(values, ", "))
+	fs.VarP(&enumValue{value: p, allowed: values}, name, shorthand, usage)
+	fs.SetAnnotation(name, FlagEnumAnnotation, values)
+	if descriptions != nil {
+		fs.SetAnnotation(name, FlagEnumDescriptionAnnotation, descriptions)
+	}
+	return p
+}
+
+// 将 "取值#说明" 形式的可选取值拆分为取值和说明，没有任何取值附带说明时 descriptions 为 nil
+func splitEnumDescriptions(allowed []string) (values []string, descriptions []string) {
+	values = make([]string, len(allowed))
+	for i, a := range allowed {
+		parts := strings.SplitN(a, "#", 2)
+		values[i] = parts[0]
+		if len(parts) == 2 {
+			if descriptions == nil {
+				descriptions = make([]string, len(allowed))
+			}
+			descriptions[i] = parts[1]
+		}
+	}
+	return values, descriptions
+}
+
+// 返回 enum flag 中以 prefix 开头的可选取值，附带说明的取值以 "取值\t说明" 的形式返回，支持说明的 shell 会一并显示
+func enumCandidates(f *flag.Flag, prefix string) []string {
+	descriptions := f.Annotations[FlagEnumDescriptionAnnotation]
+	candidates := []string{}
+	for i, value := range f.Annotations[FlagEnumAnnotation] {
+		if !strings.HasPrefix(value, prefix) {
+			continue
+		}
+		if i < len(descriptions) && descriptions[i] != "" {
+			value += "\t" + descriptions[i]
+		}
+		candidates = append(candidates, value)
+	}
+	return candidates
+}
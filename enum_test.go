@@ -0,0 +1,61 @@
+package bobra
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// 测试 Enum 注册的 flag 在解析时校验取值，在使用方法中列出可选取值，并用于补全
+func TestEnum(t *testing.T) {
+	var format string
+	c := &Command{
+		Use:          "get",
+		SilenceUsage: true,
+		Run: func(cmd *Command, args []string) {
+			format, _ = cmd.Flags().GetString("format")
+		},
+	}
+	p := Enum(c.LocalFlags(), "format", "table", []string{"table", "json", "yaml"}, "output format")
+	if *p != "table" {
+		t.Errorf("expected default 'table' but got '%s'", *p)
+	}
+
+	if err := c.ExecuteLine("--format json"); err != nil || format != "json" {
+		t.Errorf("expected 'json' but got '%s', error '%v'", format, err)
+	}
+	err := c.ExecuteLine("--format xml")
+	if err == nil || !strings.Contains(err.Error(), "must be one of: table, json, yaml") {
+		t.Errorf("expected invalid value error but got '%v'", err)
+	}
+
+	if usages := c.LocalFlags().FlagUsages(); !strings.Contains(usages, "output format (one of: table, json, yaml)") {
+		t.Errorf("expected allowed values in usages but got '%s'", usages)
+	}
+
+	candidates, directive := c.CompleteFlag("format", []string{}, "y")
+	if !reflect.DeepEqual(candidates, []string{"yaml"}) || directive != ShellCompDirectiveNoFileComp {
+		t.Errorf("expected '[yaml]' but got '%q', %d", candidates, directive)
+	}
+	if _, directive := c.CompleteFlag("missing", []string{}, ""); directive != ShellCompDirectiveError {
+		t.Errorf("expected error directive for unknown flag but got %d", directive)
+	}
+}
+
+// 测试 Enum 的可选取值可以附带说明，补全时说明以制表符分隔跟随在取值之后
+func TestEnum_Descriptions(t *testing.T) {
+	c := &Command{Use: "serve", Run: func(cmd *Command, args []string) {}}
+	Enum(c.LocalFlags(), "log-level", "info", []string{"debug#verbose output", "info", "error#only errors"}, "log level")
+
+	candidates, _ := c.CompleteFlag("log-level", []string{}, "")
+	expected := []string{"debug\tverbose output", "info", "error\tonly errors"}
+	if !reflect.DeepEqual(candidates, expected) {
+		t.Errorf("expected '%q' but got '%q'", expected, candidates)
+	}
+	if err := c.ExecuteLine("--log-level debug"); err != nil {
+		t.Errorf("expected value without description to be valid but got '%v'", err)
+	}
+	if usages := c.LocalFlags().FlagUsages(); !strings.Contains(usages, "log level (one of: debug, info, error)") {
+		t.Errorf("expected allowed values without descriptions in usages but got '%s'", usages)
+	}
+}
@@ -0,0 +1,63 @@
+package bobra
+
+import (
+	"os"
+	"strings"
+
+	flag "github.com/spf13/pflag"
+)
+
+// flag 取值的来源
+type FlagSource string
+
+const (
+	// 在命令行中指定
+	FlagSourceFlag FlagSource = "flag"
+	// 来自环境变量
+	FlagSourceEnv FlagSource = "env"
+	// 来自配置文件或者 SetConfigResolver 设置的函数
+	FlagSourceConfig FlagSource = "config"
+	// 使用默认值
+	FlagSourceDefault FlagSource = "default"
+)
+
+// 让命令及其子命令中命令行里未指定的 flags 从环境变量 PREFIX_FLAG_NAME 中读取取值，
+// 如前缀为 "app" 时 --log-level 对应 APP_LOG_LEVEL
+func (c *Command) BindFlagsToEnv(prefix string) {
+	c.envPrefix = &prefix
+}
+
+// 返回名为 name 的 flag 对应的环境变量名称，命令及其父命令都没有调用 BindFlagsToEnv 时返回空字符串
+func (c *Command) flagEnvName(name string) string {
+	for p := c; p != nil; p = p.parent {
+		if p.envPrefix == nil {
+			continue
+		}
+		env := strings.ToUpper(strings.Replace(name, "-", "_", -1))
+		if *p.envPrefix != "" {
+			env = strings.ToUpper(*p.envPrefix) + "_" + env
+		}
+		return env
+	}
+	return ""
+}
+
+// 返回环境变量中 flag 的取值以及对应的环境变量名称，没有绑定环境变量或者环境变量不存在时 ok 为 false
+func (c *Command) envFlagValue(f *flag.Flag) (env string, value string, ok bool) {
+	if env = c.flagEnvName(f.Name); env == "" {
+		return "", "", false
+	}
+	value, ok = os.LookupEnv(env)
+	return env, value, ok
+}
+
+// 返回名为 name 的 flag 在本次执行中取值的来源
+func (c *Command) FlagSource(name string) FlagSource {
+	if source, ok := c.flagSources[name]; ok {
+		return source
+	}
+	if c.Flags().Changed(name) {
+		return FlagSourceFlag
+	}
+	return FlagSourceDefault
+}
@@ -0,0 +1,71 @@
+package bobra
+
+import (
+	"os"
+	"testing"
+)
+
+// 测试命令行中未指定的 flags 从环境变量中读取取值，并且可以查询取值来源
+func TestCommand_BindFlagsToEnv(t *testing.T) {
+	var host string
+	var port int
+	var sources map[string]FlagSource
+	r := &Command{Use: "app"}
+	sub := &Command{
+		Use: "serve",
+		Run: func(cmd *Command, args []string) {
+			host, _ = cmd.Flags().GetString("listen-host")
+			port, _ = cmd.Flags().GetInt("port")
+			sources = map[string]FlagSource{}
+			for _, name := range []string{"listen-host", "port", "verbose"} {
+				sources[name] = cmd.FlagSource(name)
+			}
+		},
+	}
+	sub.LocalFlags().String("listen-host", "localhost", "host to listen on")
+	sub.LocalFlags().Int("port", 80, "port to listen on")
+	sub.LocalFlags().Bool("verbose", false, "verbose output")
+	r.AddCommand(sub)
+	r.BindFlagsToEnv("app")
+
+	os.Setenv("APP_LISTEN_HOST", "0.0.0.0")
+	os.Setenv("APP_PORT", "8080")
+	defer os.Unsetenv("APP_LISTEN_HOST")
+	defer os.Unsetenv("APP_PORT")
+
+	if err := r.ExecuteLine("serve --port 9090"); err != nil {
+		t.Fatal(err)
+	}
+	if host != "0.0.0.0" || port != 9090 {
+		t.Errorf("expected 0.0.0.0:9090 but got %s:%d", host, port)
+	}
+	expected := map[string]FlagSource{"listen-host": FlagSourceEnv, "port": FlagSourceFlag, "verbose": FlagSourceDefault}
+	for name, source := range expected {
+		if sources[name] != source {
+			t.Errorf("expected source of '--%s' to be '%s' but got '%s'", name, source, sources[name])
+		}
+	}
+
+	r.Reset()
+	os.Setenv("APP_PORT", "http")
+	if err := r.ExecuteLine("serve"); err == nil {
+		t.Errorf("expected error for invalid value in environment variable")
+	}
+}
+
+// 测试必填的 flag 可以由环境变量提供
+func TestCommand_BindFlagsToEnvRequired(t *testing.T) {
+	c := &Command{Use: "deploy", SilenceUsage: true, Run: func(cmd *Command, args []string) {}}
+	c.LocalFlags().String("token", "", "api token")
+	c.MarkFlagRequired("token")
+	c.BindFlagsToEnv("deploy")
+
+	if err := c.ExecuteLine(""); err == nil {
+		t.Errorf("expected error without '--token'")
+	}
+	os.Setenv("DEPLOY_TOKEN", "secret")
+	defer os.Unsetenv("DEPLOY_TOKEN")
+	if err := c.ExecuteLine(""); err != nil {
+		t.Errorf("expected '--token' to be read from environment, but got '%v'", err)
+	}
+}
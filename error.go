@@ -4,11 +4,18 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"strings"
 )
 
 var(
 	// 当找到 "help" 等命令行参数时抛出
 	FoundHelp = errors.New("Found Help")
+	// 找不到要执行的命令，可以通过 errors.Is 判断
+	ErrUnknownCommand = errors.New("unknown command")
+	// 解析 flags 失败，可以通过 errors.Is 判断
+	ErrFlagParse = errors.New("flag parse error")
+	// 由 PreRunE 或 PersistentPreRunE 返回，表示不需要执行命令但不是错误，Execute 返回 nil
+	ErrSkipRun = errors.New("skip run")
 )
 // 当命令没有找到时抛出
 type ObjectNotFound struct {
@@ -20,6 +27,120 @@ func (e ObjectNotFound) Error() string {
 	return fmt.Sprintf("An instance of %s, name '%s' doesn't exist.", e.Type, e.Name)
 }
 
+// 找不到的对象为命令时，与 ErrUnknownCommand 匹配
+func (e ObjectNotFound) Is(target error) bool {
+	return target == ErrUnknownCommand && e.Type == "Command"
+}
+
+// 当输入的子命令不存在时抛出，Suggestions 为名称相近的子命令
+type UnknownCommandError struct {
+	Command     string
+	Name        string
+	Suggestions []string
+}
+
+func (e UnknownCommandError) Error() string {
+	msg := fmt.Sprintf("unknown command '%s' for '%s'", e.Name, e.Command)
+	if len(e.Suggestions) > 0 {
+		msg += "\n\nDid you mean this?\n\t" + strings.Join(e.Suggestions, "\n\t")
+	}
+	return msg
+}
+
+// 与 ErrUnknownCommand 匹配
+func (e UnknownCommandError) Is(target error) bool {
+	return target == ErrUnknownCommand
+}
+
+// 当解析命令的 flags 失败时抛出
+type FlagParseError struct {
+	Command string
+	Err     error
+}
+
+func (e FlagParseError) Error() string {
+	return e.Err.Error()
+}
+
+// 返回 pflag 返回的原始错误
+func (e FlagParseError) Unwrap() error {
+	return e.Err
+}
+
+// 与 ErrFlagParse 匹配
+func (e FlagParseError) Is(target error) bool {
+	return target == ErrFlagParse
+}
+
+// 当要执行的命令被禁用时抛出
+type CommandNotAvailable struct {
+	Name string
+}
+
+func (e CommandNotAvailable) Error() string {
+	return fmt.Sprintf("Command '%s' is not available.", e.Name)
+}
+
+// 当添加的对象与已有的对象重名时抛出
+type ObjectExists struct {
+	Type string
+	Name string
+}
+
+func (e ObjectExists) Error() string {
+	return fmt.Sprintf("An instance of %s, name '%s' already exists.", e.Type, e.Name)
+}
+
+// 当配置文件格式错误时抛出
+type InvalidConfig struct {
+	Path string
+	Line int
+}
+
+func (e InvalidConfig) Error() string {
+	return fmt.Sprintf("Config file '%s' is invalid at line %d.", e.Path, e.Line)
+}
+
+// 当设置了 RecoverPanics 的命令在执行期间 panic 时抛出
+type PanicError struct {
+	Command string
+	Value   interface{}
+}
+
+func (e PanicError) Error() string {
+	return fmt.Sprintf("Command '%s' panicked: %v", e.Command, e.Value)
+}
+
+// 命令执行失败时携带进程退出码的错误，由 ExecuteAndExit 转换为对应的退出码
+type ExitError struct {
+	Code int
+	Err  error
+}
+
+func (e ExitError) Error() string {
+	if e.Err == nil {
+		return fmt.Sprintf("exit status %d", e.Code)
+	}
+	return e.Err.Error()
+}
+
+// 返回被包装的原始错误
+func (e ExitError) Unwrap() error {
+	return e.Err
+}
+
+// 返回错误对应的进程退出码，没有错误时为 0，错误链中没有 ExitError 时为 1
+func exitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	var exitErr ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.Code
+	}
+	return 1
+}
+
 // 打印异常的函数
 func LogError(e error) {
 	fmt.Fprintln(os.Stderr, e.Error())
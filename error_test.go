@@ -0,0 +1,193 @@
+package bobra
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// 测试通过 errors.Is 区分找不到命令和解析 flags 失败的错误
+func TestErrors_Is(t *testing.T) {
+	r := &Command{Use: "root"}
+	sub := &Command{Use: "sub", Run: func(cmd *Command, args []string) {}}
+	sub.LocalFlags().Int("port", 80, "listen port")
+	r.AddCommand(sub)
+
+	os.Args = []string{"root", "missing"}
+	err := r.Execute()
+	if !errors.Is(err, ErrUnknownCommand) || errors.Is(err, ErrFlagParse) {
+		t.Errorf("expected unknown command error but got '%v'", err)
+	}
+
+	os.Args = []string{"root", "sub", "--port", "abc"}
+	err = r.Execute()
+	if !errors.Is(err, ErrFlagParse) || errors.Is(err, ErrUnknownCommand) {
+		t.Errorf("expected flag parse error but got '%v'", err)
+	}
+	var parseErr FlagParseError
+	if !errors.As(err, &parseErr) || parseErr.Command != "root sub" {
+		t.Errorf("expected FlagParseError for 'root sub' but got '%v'", err)
+	}
+}
+
+// 测试 SetFlagErrorFunc 设置的函数可以改写子命令的 flags 解析错误
+func TestCommand_SetFlagErrorFunc(t *testing.T) {
+	r := &Command{Use: "root"}
+	sub := &Command{Use: "sub", Run: func(cmd *Command, args []string) {}}
+	sub.LocalFlags().Int("port", 80, "listen port")
+	r.AddCommand(sub)
+	r.SetFlagErrorFunc(func(cmd *Command, err error) error {
+		return fmt.Errorf("%w\nSee '%s --help'", err, cmd.CommandPath())
+	})
+
+	err := r.ExecuteLine("sub --port abc")
+	if !errors.Is(err, ErrFlagParse) {
+		t.Errorf("expected wrapped flag parse error but got '%v'", err)
+	}
+	expected := "See 'root sub --help'"
+	if err == nil || !strings.HasSuffix(err.Error(), expected) {
+		t.Errorf("expected error ending with '%s' but got '%v'", expected, err)
+	}
+}
+
+// 测试 ExecuteAndExit 根据命令返回的错误决定退出码
+func TestExecuteAndExit(t *testing.T) {
+	defer func() { osExit = os.Exit }()
+	var code int
+	osExit = func(c int) { code = c }
+
+	var runErr error
+	r := &Command{
+		Use:  "root",
+		RunE: func(cmd *Command, args []string) error { return runErr },
+	}
+	r.SetArgs([]string{})
+	tests := []struct {
+		err  error
+		code int
+	}{
+		{nil, 0},
+		{errors.New("failed"), 1},
+		{ExitError{Code: 3, Err: errors.New("not found")}, 3},
+		{fmt.Errorf("wrapped: %w", ExitError{Code: 4}), 4},
+	}
+	for _, test := range tests {
+		runErr = test.err
+		code = -1
+		ExecuteAndExit(r)
+		if code != test.code {
+			t.Errorf("expected exit code %d for '%v' but got %d", test.code, test.err, code)
+		}
+	}
+}
+
+// 测试输入不存在的子命令时返回包含相近子命令建议的 UnknownCommandError
+func TestUnknownCommandError(t *testing.T) {
+	r := &Command{Use: "root"}
+	for _, name := range []string{"status", "start", "stop", "deploy"} {
+		r.AddCommand(&Command{Use: name, Run: func(cmd *Command, args []string) {}})
+	}
+
+	r.SetArgs([]string{"stat"})
+	err := r.Execute()
+	var unknown UnknownCommandError
+	if !errors.As(err, &unknown) || !errors.Is(err, ErrUnknownCommand) {
+		t.Fatalf("expected UnknownCommandError but got '%v'", err)
+	}
+	if !reflect.DeepEqual(unknown.Suggestions, []string{"start", "status", "stop"}) {
+		t.Errorf("expected '[start status stop]' but got '%q'", unknown.Suggestions)
+	}
+
+	r.SetArgs([]string{"statsu"})
+	err = r.Execute()
+	expected := "unknown command 'statsu' for 'root'\n\nDid you mean this?\n\tstatus"
+	if err.Error() != expected {
+		t.Errorf("expected '%s' but got '%s'", expected, err.Error())
+	}
+
+	r.SetArgs([]string{"xyz"})
+	if err := r.Execute(); err == nil || err.Error() != "unknown command 'xyz' for 'root'" {
+		t.Errorf("expected error without suggestions but got '%v'", err)
+	}
+}
+
+// 测试声明了位置参数的命令即使有子命令也接受不是子命令的参数，不能运行的叶子命令输出使用方法
+func TestUnknownCommandError_AcceptsArgs(t *testing.T) {
+	var path string
+	r := &Command{Use: "git <path>", Args: MaximumNArgs(1), Run: func(cmd *Command, args []string) { path = args[0] }}
+	r.AddCommand(&Command{Use: "status", Run: func(cmd *Command, args []string) {}})
+	r.SetArgs([]string{"somepath"})
+	if err := r.Execute(); err != nil || path != "somepath" {
+		t.Errorf("expected 'somepath' to be accepted as argument but got '%s', error '%v'", path, err)
+	}
+
+	var usage string
+	r.usageFunc = func(cmd *Command) error {
+		usage = cmd.CommandPath()
+		return nil
+	}
+	r.AddCommand(&Command{Use: "remote"})
+	r.SetArgs([]string{"remote", "origin"})
+	if err := r.Execute(); err != nil || usage != "git remote" {
+		t.Errorf("expected usage of 'git remote' but got '%s', error '%v'", usage, err)
+	}
+}
+
+// 测试开启 EnableInteractiveSuggestions 时在终端中选择建议的子命令并执行
+func TestCommand_EnableInteractiveSuggestions(t *testing.T) {
+	defer func(f func(io.Reader) bool) { isInteractive = f }(isInteractive)
+	defer func(f func(io.Writer) bool) { isTerminal = f }(isTerminal)
+	isTerminal = func(w io.Writer) bool { return true }
+
+	var ran, ranArgs string
+	r := &Command{Use: "root", EnableInteractiveSuggestions: true}
+	for _, name := range []string{"status", "start", "stop"} {
+		r.AddCommand(&Command{Use: name, Run: func(cmd *Command, args []string) {
+			ran, ranArgs = cmd.Name(), strings.Join(args, " ")
+		}})
+	}
+
+	isInteractive = func(r io.Reader) bool { return true }
+	r.SetIn(strings.NewReader("2\n"))
+	r.SetArgs([]string{"stat", "web"})
+	if err := r.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	if ran != "status" || ranArgs != "web" {
+		t.Errorf("expected 'status web' to run but got '%s %s'", ran, ranArgs)
+	}
+
+	ran = ""
+	r.SetIn(strings.NewReader("\n"))
+	if err := r.Execute(); !errors.Is(err, ErrUnknownCommand) || ran != "" {
+		t.Errorf("expected UnknownCommandError after cancelling but got '%v'", err)
+	}
+
+	isInteractive = func(r io.Reader) bool { return false }
+	r.SetIn(strings.NewReader("2\n"))
+	if err := r.Execute(); !errors.Is(err, ErrUnknownCommand) || ran != "" {
+		t.Errorf("expected UnknownCommandError without terminal but got '%v'", err)
+	}
+}
+
+// 测试计算编辑距离
+func Test_Levenshtein(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		distance int
+	}{
+		{"", "abc", 3},
+		{"stop", "stop", 0},
+		{"statsu", "status", 2},
+		{"kitten", "sitting", 3},
+	}
+	for _, test := range tests {
+		if d := levenshtein(test.a, test.b); d != test.distance {
+			t.Errorf("expected distance %d between '%s' and '%s' but got %d", test.distance, test.a, test.b, d)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package bobra
+
+import (
+	"bytes"
+
+	flag "github.com/spf13/pflag"
+)
+
+// 使用方法中单独显示的一组局部 flags
+type FlagSection struct {
+	// 该组 flags 的标题
+	Title string
+	// 该组中的 flags
+	Flags *flag.FlagSet
+}
+
+// 返回该组中未隐藏的 flags 的数量
+func (s FlagSection) Count() int {
+	return countVisibleFlags(s.Flags)
+}
+
+// 返回名为 title 的局部 flags 分组，不存在时创建。在分组中注册的 flags 与 LocalFlags 中的相同，
+// 但是在使用方法中以 title 为标题单独显示，分组按照创建的顺序显示在 LocalFlags 之后
+func (c *Command) FlagGroup(title string) *flag.FlagSet {
+	for _, section := range c.flagGroups {
+		if section.Title == title {
+			return section.Flags
+		}
+	}
+	if c.flagErrorBuf == nil {
+		c.flagErrorBuf = new(bytes.Buffer)
+	}
+	fs := flag.NewFlagSet(c.Name(), flag.ContinueOnError)
+	fs.SetOutput(c.flagErrorBuf)
+	c.flagGroups = append(c.flagGroups, FlagSection{Title: title, Flags: fs})
+	return fs
+}
+
+// 返回包含未隐藏的 flags 的分组，用于使用方法
+func (c *Command) FlagSections() []FlagSection {
+	sections := []FlagSection{}
+	for _, section := range c.flagGroups {
+		if section.Count() > 0 {
+			sections = append(sections, section)
+		}
+	}
+	return sections
+}
+
+// 返回不属于任何分组的局部 flags，显示在使用方法的 LocalFlags 部分
+func (c *Command) UngroupedFlags() *flag.FlagSet {
+	ungrouped := flag.NewFlagSet(c.Name(), flag.ContinueOnError)
+	ungrouped.SetOutput(c.flagErrorBuf)
+	c.LocalFlags().VisitAll(func(f *flag.Flag) {
+		for _, section := range c.flagGroups {
+			if section.Flags.Lookup(f.Name) != nil {
+				return
+			}
+		}
+		ungrouped.AddFlag(f)
+	})
+	return ungrouped
+}
+
+// 返回不属于任何分组的未隐藏的局部 flags 的数量
+func (c *Command) UngroupedFlagCount() int {
+	return countVisibleFlags(c.UngroupedFlags())
+}
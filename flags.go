@@ -0,0 +1,551 @@
+package bobra
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	flag "github.com/spf13/pflag"
+)
+
+// 标记 flag 为必填的标注
+const FlagRequiredAnnotation = "bobra_annotation_required_flag"
+
+// 一个 flag 的完整元信息
+type FlagInfo struct {
+	Name       string `json:"name"`
+	Shorthand  string `json:"shorthand,omitempty"`
+	Type       string `json:"type"`
+	Default    string `json:"default"`
+	Usage      string `json:"usage"`
+	Required   bool   `json:"required"`
+	Hidden     bool   `json:"hidden"`
+	Deprecated string `json:"deprecated,omitempty"`
+}
+
+// 返回命令可用的全部 flags 的元信息，包含局部、全局以及继承自父命令的 flags
+func (c *Command) FlagMetadata() []FlagInfo {
+	c.resolveFlagDefaults()
+	infos := []FlagInfo{}
+	c.Flags().VisitAll(func(f *flag.Flag) {
+		infos = append(infos, FlagInfo{
+			Name:       f.Name,
+			Shorthand:  f.Shorthand,
+			Type:       f.Value.Type(),
+			Default:    f.DefValue,
+			Usage:      f.Usage,
+			Required:   isFlagRequired(f),
+			Hidden:     f.Hidden,
+			Deprecated: f.Deprecated,
+		})
+	})
+	return infos
+}
+
+// 判断 flag 是否被标记为必填
+func isFlagRequired(f *flag.Flag) bool {
+	values, ok := f.Annotations[FlagRequiredAnnotation]
+	return ok && len(values) > 0 && values[0] == "true"
+}
+
+// 解析 flags 时可以忽略的错误
+type FParseErrWhitelist flag.ParseErrorsWhitelist
+
+// 返回本次执行中因为设置了 FParseErrWhitelist.UnknownFlags 而被忽略的未知 flags 及其取值，保持原来的顺序。
+// 与 pflag 相同，没有用 "=" 指定取值的未知 flag 后面不以 "-" 开头的参数被视为它的取值
+func (c *Command) UnknownFlags() []string {
+	unknown := []string{}
+	fs := c.Flags()
+	args := c.rawArgs
+	for len(args) > 0 {
+		s := args[0]
+		args = args[1:]
+		if s == "--" {
+			break
+		}
+		if len(s) < 2 || s[0] != '-' {
+			continue
+		}
+		var f *flag.Flag
+		var inlineValue bool
+		if strings.HasPrefix(s, "--") {
+			f = fs.Lookup(strings.SplitN(s[2:], "=", 2)[0])
+			inlineValue = strings.Contains(s, "=")
+		} else {
+			f = fs.ShorthandLookup(s[1:2])
+			inlineValue = len(s) > 2 && (f != nil || s[2] == '=')
+		}
+		takesValue := !inlineValue && len(args) > 0 && !strings.HasPrefix(args[0], "-")
+		if f == nil {
+			unknown = append(unknown, s)
+			if takesValue {
+				unknown = append(unknown, args[0])
+				args = args[1:]
+			}
+		} else if takesValue && f.NoOptDefVal == "" {
+			args = args[1:]
+		}
+	}
+	return unknown
+}
+
+// 将名为 name 的 flag 标记为必填，命令行中没有指定并且无法从环境变量或配置中取得时执行失败
+func (c *Command) MarkFlagRequired(name string) error {
+	if err := c.Flags().SetAnnotation(name, FlagRequiredAnnotation, []string{"true"}); err != nil {
+		return ObjectNotFound{Type: "Flag", Name: name}
+	}
+	return nil
+}
+
+// 将名为 name 的 flag 标记为废弃，命令行中指定它时输出包含 message 的警告，并且不再显示在使用方法中
+func (c *Command) MarkFlagDeprecated(name string, message string) error {
+	if c.Flags().Lookup(name) == nil {
+		return ObjectNotFound{Type: "Flag", Name: name}
+	}
+	return c.Flags().MarkDeprecated(name, message)
+}
+
+// 将名为 name 的 flag 标记为隐藏，它仍然可以使用，但不会显示在使用方法中
+func (c *Command) MarkFlagHidden(name string) error {
+	if err := c.Flags().MarkHidden(name); err != nil {
+		return ObjectNotFound{Type: "Flag", Name: name}
+	}
+	return nil
+}
+
+// 检查被标记为必填的 flags 是否都取得了默认值以外的取值，返回列出全部缺少的 flags 的错误
+func (c *Command) validateRequiredFlags() error {
+	missing := []string{}
+	c.Flags().VisitAll(func(f *flag.Flag) {
+		if isFlagRequired(f) && c.FlagSource(f.Name) == FlagSourceDefault {
+			missing = append(missing, "'--"+f.Name+"'")
+		}
+	})
+	if len(missing) > 0 {
+		return fmt.Errorf("required flag(s) %s not set", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// 从命令中移除名为 name 的局部或全局 flag，以及为它注册的分组、依赖、校验、转换和补全函数。
+// pflag 不支持删除，因此需要重建命令的 flags 集合，移除的全局 flag 也不再被子命令继承
+func (c *Command) RemoveFlag(name string) {
+	removed := c.Flags().Lookup(name)
+	for i, section := range c.flagGroups {
+		c.flagGroups[i].Flags = c.withoutFlag(section.Flags, name)
+	}
+	c.localflags = c.withoutFlag(c.LocalFlags(), name)
+	c.persistentflags = c.withoutFlag(c.PersistentFlags(), name)
+	c.flags = c.withoutFlag(c.Flags(), name)
+	c.forgetFlag(name)
+
+	// 子命令合并后的 flags 中可能仍然保留着继承来的该 flag
+	var visit func(cmd *Command)
+	visit = func(cmd *Command) {
+		for _, sub := range cmd.commands {
+			if removed != nil && sub.flags != nil && sub.flags.Lookup(name) == removed {
+				sub.flags = sub.withoutFlag(sub.flags, name)
+			}
+			visit(sub)
+		}
+	}
+	visit(c)
+}
+
+// 清除为名为 name 的 flag 注册的分组、依赖、校验、转换和补全函数
+func (c *Command) forgetFlag(name string) {
+	delete(c.flagDependencies, name)
+	for dependent, required := range c.flagDependencies {
+		if required = removeAllMatchStr(required, name); len(required) > 0 {
+			c.flagDependencies[dependent] = required
+		} else {
+			delete(c.flagDependencies, dependent)
+		}
+	}
+	c.flagsRequiredTogether = withoutFlagInGroups(c.flagsRequiredTogether, name)
+	c.flagsOneRequired = withoutFlagInGroups(c.flagsOneRequired, name)
+	delete(c.flagValidators, name)
+	delete(c.flagTransforms, name)
+	delete(c.flagCompletionFuncs, name)
+}
+
+// 从每个 flags 分组中删除 name，删除后为空的分组被丢弃
+func withoutFlagInGroups(groups [][]string, name string) [][]string {
+	kept := [][]string{}
+	for _, group := range groups {
+		if group = removeAllMatchStr(group, name); len(group) > 0 {
+			kept = append(kept, group)
+		}
+	}
+	return kept
+}
+
+// 返回一个不包含名为 name 的 flag 的 fs 副本，被移除的 flag 的标注会一并清除
+func (c *Command) withoutFlag(fs *flag.FlagSet, name string) *flag.FlagSet {
+	rebuilt := flag.NewFlagSet(c.Name(), flag.ContinueOnError)
+	rebuilt.SetOutput(c.flagErrorBuf)
+	fs.VisitAll(func(f *flag.Flag) {
+		if f.Name == name {
+			f.Annotations = nil
+			return
+		}
+		rebuilt.AddFlag(f)
+	})
+	return rebuilt
+}
+
+// 将 flag 恢复为默认值并清除其被指定的标记
+func resetFlag(f *flag.Flag) {
+	if l, ok := f.Value.(*lazyDefaultValue); ok {
+		l.Value.Set(f.DefValue)
+		l.set = false
+	} else if s, ok := f.Value.(flag.SliceValue); ok {
+		values := []string{}
+		if def := strings.Trim(f.DefValue, "[]"); def != "" {
+			values = strings.Split(def, ",")
+		}
+		s.Replace(values)
+		// pflag 的切片取值无法清除被设置过的状态，需要由 resetSliceValue 让下一次设置替换默认值
+		if r, ok := f.Value.(*resetSliceValue); ok {
+			r.reset = true
+		} else if f.Changed {
+			f.Value = &resetSliceValue{Value: f.Value, SliceValue: s, reset: true}
+		}
+	} else {
+		f.Value.Set(f.DefValue)
+	}
+	f.Changed = false
+}
+
+// 被 Reset 恢复为默认值的切片 flag 取值，恢复之后的第一次设置替换默认值，之后的设置与 pflag 相同追加到已有的取值之后
+type resetSliceValue struct {
+	flag.Value
+	flag.SliceValue
+	// 恢复为默认值之后是否还没有被设置
+	reset bool
+}
+
+func (r *resetSliceValue) Set(v string) error {
+	if !r.reset {
+		return r.Value.Set(v)
+	}
+	r.reset = false
+	values, err := splitSliceValue(r.Value, v)
+	if err != nil {
+		return err
+	}
+	return r.Replace(values)
+}
+
+// 用来自配置或环境变量的 v 设置 flag 的取值。切片类型的 flag 用 v 替换已有的取值，
+// 因为 pflag 的切片取值被设置过一次之后再次设置会追加到已有的取值之后
+func setFlagValue(f *flag.Flag, v string) error {
+	s, ok := f.Value.(flag.SliceValue)
+	if !ok {
+		return f.Value.Set(v)
+	}
+	values, err := splitSliceValue(f.Value, v)
+	if err != nil {
+		return err
+	}
+	return s.Replace(values)
+}
+
+// 与 pflag 相同，将切片 flag 的一次取值 v 按照 CSV 的格式切分，stringArray 类型的取值不切分
+func splitSliceValue(value flag.Value, v string) ([]string, error) {
+	if value.Type() == "stringArray" {
+		return []string{v}, nil
+	}
+	values, err := csv.NewReader(strings.NewReader(v)).Read()
+	if err == io.EOF {
+		return []string{}, nil
+	}
+	return values, err
+}
+
+// 默认值在第一次使用时才计算的 flag 取值
+type lazyDefaultValue struct {
+	flag.Value
+	flag *flag.Flag
+	fn   func() string
+	// 默认值是否还没有计算
+	pending bool
+	// 是否通过命令行、环境变量或配置设置了取值
+	set bool
+}
+
+// 计算默认值并更新 flag 的默认值，取值没有被设置时同时更新取值
+func (v *lazyDefaultValue) resolve() {
+	if !v.pending {
+		return
+	}
+	v.pending = false
+	def := v.fn()
+	if !v.set {
+		if err := v.Value.Set(def); err != nil {
+			return
+		}
+	}
+	v.flag.DefValue = def
+}
+
+// 取值被设置时不需要计算默认值
+func (v *lazyDefaultValue) String() string {
+	if !v.set {
+		v.resolve()
+	}
+	return v.Value.String()
+}
+
+func (v *lazyDefaultValue) Set(s string) error {
+	v.set = true
+	return v.Value.Set(s)
+}
+
+// 为名为 name 的 flag 设置计算默认值的函数，fn 在显示使用方法、第一次读取该 flag，
+// 或者执行命令时该 flag 没有从命令行、环境变量和配置中取得取值时才会被调用，适用于计算开销大或者依赖运行环境的默认值
+func (c *Command) SetFlagDefaultFunc(name string, fn func() string) error {
+	f := c.Flags().Lookup(name)
+	if f == nil {
+		return ObjectNotFound{Type: "Flag", Name: name}
+	}
+	if l, ok := f.Value.(*lazyDefaultValue); ok {
+		l.fn, l.pending = fn, true
+		return nil
+	}
+	f.Value = &lazyDefaultValue{Value: f.Value, flag: f, fn: fn, pending: true}
+	return nil
+}
+
+// 计算命令可用的 flags 中尚未计算的默认值，用于显示使用方法
+func (c *Command) resolveFlagDefaults() {
+	c.Flags().VisitAll(func(f *flag.Flag) {
+		if l, ok := f.Value.(*lazyDefaultValue); ok {
+			l.resolve()
+		}
+	})
+}
+
+// 计算没有被设置的 flags 的默认值，使 Run 通过 String 等函数返回的指针读取 flag 时也能得到计算的默认值
+func (c *Command) resolveUnsetFlagDefaults() {
+	c.Flags().VisitAll(func(f *flag.Flag) {
+		if l, ok := f.Value.(*lazyDefaultValue); ok && !l.set {
+			l.resolve()
+		}
+	})
+}
+
+// 为名为 name 的 flag 注册取值转换函数，解析后会用转换结果替换命令行中指定的值
+func (c *Command) RegisterFlagTransform(name string, fn func(string) (string, error)) {
+	if c.flagTransforms == nil {
+		c.flagTransforms = map[string]func(string) (string, error){}
+	}
+	c.flagTransforms[name] = fn
+}
+
+// 对命令行中指定了的 flags 执行注册的转换函数
+func (c *Command) transformFlags() error {
+	for name, fn := range c.flagTransforms {
+		f := c.Flags().Lookup(name)
+		if f == nil || !f.Changed {
+			continue
+		}
+		v, err := fn(f.Value.String())
+		if err != nil {
+			return fmt.Errorf("invalid value '%s' for flag '%s': %v", f.Value.String(), name, err)
+		}
+		if err := f.Value.Set(v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 演练模式参数的名称
+const dryRunFlagName = "dry-run"
+
+// 为根命令注册全局的 --dry-run 参数，命令的处理函数可以通过 DryRun 判断是否只演练而不真正执行
+func (c *Command) EnableDryRunFlag() {
+	c.Root().PersistentFlags().Bool(dryRunFlagName, false, "print what would be done without doing it")
+}
+
+// 判断本次执行是否指定了 --dry-run，未注册该参数时返回 false
+func (c *Command) DryRun() bool {
+	dryRun, err := c.Flags().GetBool(dryRunFlagName)
+	return err == nil && dryRun
+}
+
+// 将名为 oldName 的 flag 标记为废弃并由 newName 替代，解析后如果只指定了旧的 flag，则将它的值复制到新的 flag 中
+func (c *Command) FlagRename(oldName, newName string) error {
+	if c.Flags().Lookup(newName) == nil {
+		return ObjectNotFound{Type: "Flag", Name: newName}
+	}
+	if err := c.Flags().MarkDeprecated(oldName, "use --"+newName+" instead"); err != nil {
+		return ObjectNotFound{Type: "Flag", Name: oldName}
+	}
+	if c.flagRenames == nil {
+		c.flagRenames = map[string]string{}
+	}
+	c.flagRenames[oldName] = newName
+	return nil
+}
+
+// 将只在命令行中指定了的废弃 flag 的值复制到替代它的 flag 中
+func (c *Command) applyFlagRenames() {
+	for oldName, newName := range c.flagRenames {
+		o, n := c.Flags().Lookup(oldName), c.Flags().Lookup(newName)
+		if o == nil || n == nil || !o.Changed || n.Changed {
+			continue
+		}
+		oldSlice, oldIsSlice := o.Value.(flag.SliceValue)
+		newSlice, newIsSlice := n.Value.(flag.SliceValue)
+		if oldIsSlice && newIsSlice {
+			newSlice.Replace(oldSlice.GetSlice())
+			n.Changed = true
+		} else {
+			c.Flags().Set(newName, o.Value.String())
+		}
+	}
+}
+
+// 声明名为 name 的 flag 依赖 requiredName，即指定了 name 时必须同时指定 requiredName
+func (c *Command) FlagRequires(name string, requiredName string) error {
+	for _, n := range []string{name, requiredName} {
+		if c.Flags().Lookup(n) == nil {
+			return ObjectNotFound{Type: "Flag", Name: n}
+		}
+	}
+	if c.flagDependencies == nil {
+		c.flagDependencies = map[string][]string{}
+	}
+	c.flagDependencies[name] = append(c.flagDependencies[name], requiredName)
+	return nil
+}
+
+// 检查指定了的 flags 所依赖的 flags 是否也被指定
+func (c *Command) validateFlagDependencies() error {
+	names := []string{}
+	for name := range c.flagDependencies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if !c.Flags().Changed(name) {
+			continue
+		}
+		for _, required := range c.flagDependencies[name] {
+			if !c.Flags().Changed(required) {
+				return fmt.Errorf("flag '--%s' requires flag '--%s' to be set", name, required)
+			}
+		}
+	}
+	return nil
+}
+
+// 声明 names 中的 flags 必须同时指定，只指定其中一部分时执行失败
+func (c *Command) MarkFlagsRequiredTogether(names ...string) error {
+	for _, name := range names {
+		if c.Flags().Lookup(name) == nil {
+			return ObjectNotFound{Type: "Flag", Name: name}
+		}
+	}
+	c.flagsRequiredTogether = append(c.flagsRequiredTogether, names)
+	return nil
+}
+
+// 声明 names 中的 flags 至少需要指定一个，都没有指定时执行失败
+func (c *Command) MarkFlagsOneRequired(names ...string) error {
+	for _, name := range names {
+		if c.Flags().Lookup(name) == nil {
+			return ObjectNotFound{Type: "Flag", Name: name}
+		}
+	}
+	c.flagsOneRequired = append(c.flagsOneRequired, names)
+	return nil
+}
+
+// 检查必须同时指定的 flags 是否只指定了一部分，以及至少需要指定一个的 flags 是否都没有指定
+func (c *Command) validateFlagGroups() error {
+	for _, group := range c.flagsRequiredTogether {
+		quoted, missing := []string{}, []string{}
+		for _, name := range group {
+			quoted = append(quoted, "'--"+name+"'")
+			if !c.Flags().Changed(name) {
+				missing = append(missing, "'--"+name+"'")
+			}
+		}
+		if len(missing) > 0 && len(missing) < len(group) {
+			return fmt.Errorf("flags %s must be set together, missing %s", strings.Join(quoted, ", "), strings.Join(missing, ", "))
+		}
+	}
+	for _, group := range c.flagsOneRequired {
+		quoted, set := []string{}, false
+		for _, name := range group {
+			quoted = append(quoted, "'--"+name+"'")
+			set = set || c.Flags().Changed(name)
+		}
+		if !set {
+			return fmt.Errorf("at least one of the flags %s must be set", strings.Join(quoted, ", "))
+		}
+	}
+	return nil
+}
+
+// 为名为 name 的 flag 注册校验取值的函数，解析后对取得了默认值以外的取值的 flag 调用，
+// 全部校验失败的 flags 会在同一个错误中列出。为父命令的全局 flag 注册的函数对子命令同样有效
+func (c *Command) ValidateFlag(name string, fn func(string) error) error {
+	if c.Flags().Lookup(name) == nil {
+		return ObjectNotFound{Type: "Flag", Name: name}
+	}
+	if c.flagValidators == nil {
+		c.flagValidators = map[string][]func(string) error{}
+	}
+	c.flagValidators[name] = append(c.flagValidators[name], fn)
+	return nil
+}
+
+// 对取得了默认值以外的取值的 flags 调用注册的校验函数，返回列出全部校验失败的 flags 的错误
+func (c *Command) validateFlagValues() error {
+	violations := []string{}
+	c.Flags().VisitAll(func(f *flag.Flag) {
+		if c.FlagSource(f.Name) == FlagSourceDefault {
+			return
+		}
+		for p := c; p != nil; p = p.parent {
+			for _, fn := range p.flagValidators[f.Name] {
+				if err := fn(f.Value.String()); err != nil {
+					violations = append(violations, fmt.Sprintf("'--%s=%s': %v", f.Name, f.Value.String(), err))
+				}
+			}
+		}
+	})
+	if len(violations) > 0 {
+		return fmt.Errorf("invalid flag value(s):\n  %s", strings.Join(violations, "\n  "))
+	}
+	return nil
+}
+
+// 返回局部 flags 及其默认值的列表，每行一个 flag，用于使用方法中的 Defaults 部分
+func (c *Command) FlagDefaults() string {
+	width := 0
+	c.LocalFlags().VisitAll(func(f *flag.Flag) {
+		if !f.Hidden && len(f.Name) > width {
+			width = len(f.Name)
+		}
+	})
+	var b strings.Builder
+	c.LocalFlags().VisitAll(func(f *flag.Flag) {
+		if f.Hidden {
+			return
+		}
+		value := f.DefValue
+		if f.Value.Type() == "string" {
+			value = strconv.Quote(value)
+		}
+		fmt.Fprintf(&b, "  --%s  %s\n", rpad(f.Name, width), value)
+	})
+	return b.String()
+}
@@ -0,0 +1,536 @@
+package bobra
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// 测试 flags 元信息能够正确反映必填、隐藏以及继承的 flags
+func TestCommand_FlagMetadata(t *testing.T) {
+	r := &Command{Use: "root"}
+	sub := &Command{Use: "sub"}
+	r.AddCommand(sub)
+	r.GlobalFlags().BoolP("verbose", "v", false, "verbose output")
+	sub.LocalFlags().String("name", "", "name of the object")
+	sub.LocalFlags().SetAnnotation("name", FlagRequiredAnnotation, []string{"true"})
+	sub.LocalFlags().Int("secret", 3, "internal use only")
+	sub.LocalFlags().MarkHidden("secret")
+
+	infos := map[string]FlagInfo{}
+	for _, info := range sub.FlagMetadata() {
+		if _, ok := infos[info.Name]; ok {
+			t.Errorf("flag '%s' is reported more than once", info.Name)
+		}
+		infos[info.Name] = info
+	}
+
+	expected := map[string]FlagInfo{
+		"verbose": {Name: "verbose", Shorthand: "v", Type: "bool", Default: "false", Usage: "verbose output"},
+		"name":    {Name: "name", Type: "string", Usage: "name of the object", Required: true},
+		"secret":  {Name: "secret", Type: "int", Default: "3", Usage: "internal use only", Hidden: true},
+	}
+	for name, e := range expected {
+		if infos[name] != e {
+			t.Errorf("expected '%+v' but got '%+v'", e, infos[name])
+		}
+	}
+}
+
+// 测试移除 flag 后既无法访问也无法解析
+func TestCommand_RemoveFlag(t *testing.T) {
+	c := &Command{Use: "plugin"}
+	c.LocalFlags().String("keep", "", "kept flag")
+	c.LocalFlags().String("drop", "", "dropped flag")
+	c.LocalFlags().SetAnnotation("drop", FlagRequiredAnnotation, []string{"true"})
+	dropped := c.Flags().Lookup("drop")
+
+	c.RemoveFlag("drop")
+
+	if c.Flags().Lookup("drop") != nil || c.LocalFlags().Lookup("drop") != nil {
+		t.Errorf("expected flag 'drop' to be removed")
+	}
+	if dropped.Annotations != nil {
+		t.Errorf("expected annotations of 'drop' to be cleared, but got '%v'", dropped.Annotations)
+	}
+	if err := c.ParseFlags([]string{"--drop", "x"}); err == nil {
+		t.Errorf("expected error when parsing removed flag, but got nil")
+	}
+	if err := c.ParseFlags([]string{"--keep", "x"}); err != nil {
+		t.Errorf("expected 'keep' to parse, but got '%v'", err)
+	}
+	if r, _ := c.Flags().GetString("keep"); r != "x" {
+		t.Errorf("expected 'x' but got '%s'", r)
+	}
+}
+
+// 测试移除全局 flag 后子命令也无法访问，并且为它声明的分组和依赖不再生效
+func TestCommand_RemoveFlagState(t *testing.T) {
+	r := &Command{Use: "root", Run: func(cmd *Command, args []string) {}}
+	sub := &Command{Use: "sub", Run: func(cmd *Command, args []string) {}}
+	r.AddCommand(sub)
+	r.PersistentFlags().String("region", "", "region")
+	if sub.Flags().Lookup("region") == nil {
+		t.Fatalf("expected 'region' to be inherited")
+	}
+
+	r.RemoveFlag("region")
+	if r.Flags().Lookup("region") != nil || r.PersistentFlags().Lookup("region") != nil || sub.Flags().Lookup("region") != nil {
+		t.Errorf("expected persistent flag 'region' to be removed")
+	}
+
+	r.LocalFlags().String("a", "", "a")
+	r.LocalFlags().String("b", "", "b")
+	r.LocalFlags().String("c", "", "c")
+	r.MarkFlagsRequiredTogether("a", "b")
+	r.MarkFlagsOneRequired("b")
+	r.FlagRequires("c", "b")
+	r.ValidateFlag("b", func(string) error { return nil })
+	r.RemoveFlag("b")
+	if err := r.ExecuteLine("--a x --c y"); err != nil {
+		t.Errorf("expected groups and dependencies of removed flag 'b' to be dropped, but got '%v'", err)
+	}
+	if len(r.flagsOneRequired) != 0 || len(r.flagDependencies) != 0 || len(r.flagValidators) != 0 {
+		t.Errorf("expected state of removed flag 'b' to be cleared")
+	}
+}
+
+// 测试 flag 的取值转换，'~' 被展开为用户目录，转换失败时返回错误
+func TestCommand_RegisterFlagTransform(t *testing.T) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		t.Skip("no home directory")
+	}
+	var path string
+	c := &Command{
+		Use:          "open",
+		SilenceUsage: true,
+		Run: func(cmd *Command, args []string) {
+			path, _ = cmd.Flags().GetString("path")
+		},
+	}
+	c.LocalFlags().String("path", "", "file path")
+	c.RegisterFlagTransform("path", func(v string) (string, error) {
+		if v == "" {
+			return "", errors.New("empty path")
+		}
+		if strings.HasPrefix(v, "~/") {
+			v = filepath.Join(home, v[2:])
+		}
+		return filepath.Abs(v)
+	})
+
+	expected := filepath.Join(home, "x")
+	if err := c.execute([]string{"--path", "~/x"}); err != nil || path != expected {
+		t.Errorf("expected '%s' but got '%s', error '%v'", expected, path, err)
+	}
+	if err := c.execute([]string{"--path="}); err == nil {
+		t.Errorf("expected transform error but got nil")
+	}
+}
+
+// 测试在根命令注册的 --dry-run 对深层子命令可见
+func TestCommand_DryRun(t *testing.T) {
+	var dryRun bool
+	r := &Command{Use: "root"}
+	service := &Command{Use: "service"}
+	stop := &Command{
+		Use: "stop",
+		Run: func(cmd *Command, args []string) {
+			dryRun = cmd.DryRun()
+		},
+	}
+	r.AddCommand(service)
+	service.AddCommand(stop)
+	if stop.DryRun() {
+		t.Errorf("expected DryRun to be false before the flag is registered")
+	}
+	r.EnableDryRunFlag()
+
+	os.Args = []string{"root", "service", "stop", "--dry-run"}
+	if err := r.Execute(); err != nil || !dryRun {
+		t.Errorf("expected dry run without error, but got '%v', error '%v'", dryRun, err)
+	}
+}
+
+// 测试废弃的 flag 的值被复制到新的 flag 中，并输出废弃警告
+func TestCommand_FlagRename(t *testing.T) {
+	var name string
+	c := &Command{
+		Use: "greet",
+		Run: func(cmd *Command, args []string) {
+			name, _ = cmd.Flags().GetString("name")
+		},
+	}
+	c.LocalFlags().String("user", "", "who to greet")
+	c.LocalFlags().String("name", "nobody", "who to greet")
+	if err := c.FlagRename("user", "name"); err != nil {
+		t.Fatalf("expected no error but got '%v'", err)
+	}
+
+	out := captureStdout(t, func() {
+		c.execute([]string{"--user", "bob"})
+	})
+	if name != "bob" {
+		t.Errorf("expected 'bob' but got '%s'", name)
+	}
+	if !strings.Contains(out, "Flag --user has been deprecated, use --name instead") {
+		t.Errorf("expected deprecation warning but got '%s'", out)
+	}
+
+	if err := c.FlagRename("missing", "name"); err == nil {
+		t.Errorf("expected error for unknown flag but got nil")
+	}
+}
+
+// 测试废弃的 flag 在使用时输出警告，废弃和隐藏的 flag 都不显示在使用方法中
+func TestCommand_MarkFlagDeprecatedAndHidden(t *testing.T) {
+	var mode string
+	c := &Command{
+		Use: "serve",
+		Run: func(cmd *Command, args []string) {
+			mode, _ = cmd.Flags().GetString("mode")
+		},
+	}
+	c.LocalFlags().String("mode", "", "serving mode")
+	c.LocalFlags().Bool("debug", false, "enable debugging")
+	c.LocalFlags().Int("port", 80, "listening port")
+	if err := c.MarkFlagDeprecated("mode", "it is ignored since v2"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.MarkFlagHidden("debug"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.MarkFlagDeprecated("missing", "gone"); err == nil {
+		t.Errorf("expected error for unknown flag")
+	}
+	if err := c.MarkFlagHidden("missing"); err == nil {
+		t.Errorf("expected error for unknown flag")
+	}
+
+	out := captureStdout(t, func() {
+		c.execute([]string{"--mode", "fast", "--debug"})
+	})
+	if mode != "fast" {
+		t.Errorf("expected deprecated flag to still be set, but got '%s'", mode)
+	}
+	if !strings.Contains(out, "Flag --mode has been deprecated, it is ignored since v2") {
+		t.Errorf("expected deprecation warning but got '%s'", out)
+	}
+
+	usages := c.LocalFlags().FlagUsages()
+	if strings.Contains(usages, "--mode") || strings.Contains(usages, "--debug") {
+		t.Errorf("expected deprecated and hidden flags to be excluded from usages, but got '%s'", usages)
+	}
+	if !strings.Contains(usages, "--port") {
+		t.Errorf("expected '--port' in usages, but got '%s'", usages)
+	}
+}
+
+// 测试指定了 --replicas 而没有指定 --scale 时报错
+func TestCommand_FlagRequires(t *testing.T) {
+	c := &Command{Use: "deploy", SilenceUsage: true, Run: func(cmd *Command, args []string) {}}
+	c.LocalFlags().Int("replicas", 1, "number of replicas")
+	c.LocalFlags().Bool("scale", false, "scale the deployment")
+	if err := c.FlagRequires("replicas", "scale"); err != nil {
+		t.Fatalf("expected no error but got '%v'", err)
+	}
+
+	if err := c.execute([]string{"--replicas", "3"}); err == nil {
+		t.Errorf("expected error without --scale but got nil")
+	}
+	if err := c.execute([]string{"--replicas", "3", "--scale"}); err != nil {
+		t.Errorf("expected no error with --scale but got '%v'", err)
+	}
+}
+
+// 测试使用方法中的 Defaults 部分列出 flag 的默认值
+func TestCommand_FlagDefaults(t *testing.T) {
+	c := &Command{Use: "serve", Run: func(cmd *Command, args []string) {}}
+	c.LocalFlags().Int("port", 8080, "listen port")
+	c.LocalFlags().String("host", "", "listen host")
+
+	expected := "  --host  \"\"\n  --port  8080\n"
+	if r := c.FlagDefaults(); r != expected {
+		t.Errorf("expected '%q' but got '%q'", expected, r)
+	}
+
+	var buf bytes.Buffer
+	templify(&buf, c.UsageTemplate(), c)
+	if strings.Contains(buf.String(), "Defaults:") {
+		t.Errorf("expected no Defaults section unless enabled, but got:\n%s", buf.String())
+	}
+	c.ShowFlagDefaults = true
+	buf.Reset()
+	templify(&buf, c.UsageTemplate(), c)
+	if !strings.Contains(buf.String(), "Defaults:\n"+expected) {
+		t.Errorf("expected Defaults section listing '--port  8080', but got:\n%s", buf.String())
+	}
+}
+
+// 测试三层命令树中 persistent flags 向下继承，且不影响兄弟命令
+func TestCommand_PersistentFlags(t *testing.T) {
+	var verbose bool
+	var region string
+	r := &Command{Use: "root"}
+	cloud := &Command{Use: "cloud"}
+	deploy := &Command{
+		Use: "deploy",
+		Run: func(cmd *Command, args []string) {
+			verbose, _ = cmd.Flags().GetBool("verbose")
+			region, _ = cmd.Flags().GetString("region")
+		},
+	}
+	local := &Command{Use: "local", Run: func(cmd *Command, args []string) {}}
+	r.AddCommand(cloud, local)
+	cloud.AddCommand(deploy)
+	r.PersistentFlags().Bool("verbose", false, "verbose output")
+	cloud.PersistentFlags().String("region", "us", "deploy region")
+	deploy.LocalFlags().Int("replicas", 1, "replicas")
+
+	if err := r.ExecuteLine("cloud deploy --verbose --region eu --replicas 2"); err != nil {
+		t.Fatal(err)
+	}
+	if !verbose || region != "eu" {
+		t.Errorf("expected 'true', 'eu' but got '%v', '%s'", verbose, region)
+	}
+	if local.Flags().Lookup("region") != nil || local.Flags().Lookup("verbose") == nil {
+		t.Errorf("expected sibling command to inherit only root persistent flags")
+	}
+	if r.Flags().Lookup("region") != nil || cloud.Flags().Lookup("replicas") != nil {
+		t.Errorf("expected persistent and local flags not to propagate upwards")
+	}
+	if deploy.InheritedFlags().Lookup("region") == nil || deploy.InheritedFlags().Lookup("replicas") != nil {
+		t.Errorf("expected inherited flags to contain only ancestors' persistent flags")
+	}
+	if deploy.GlobalFlagCount() != 2 || deploy.GlobalFlags() != r.PersistentFlags() {
+		t.Errorf("expected GlobalFlags to alias the root persistent flags")
+	}
+}
+
+// 测试同名的 persistent flag 使用离命令最近的祖先命令的定义
+func TestCommand_PersistentFlagsShadowing(t *testing.T) {
+	r := &Command{Use: "root"}
+	mid := &Command{Use: "mid"}
+	leaf := &Command{Use: "leaf", Run: func(cmd *Command, args []string) {}}
+	r.AddCommand(mid)
+	mid.AddCommand(leaf)
+	r.PersistentFlags().String("output", "text", "output format")
+	mid.PersistentFlags().String("output", "json", "output format")
+
+	if f := leaf.Flags().Lookup("output"); f == nil || f.DefValue != "json" {
+		t.Errorf("expected nearest persistent flag with default 'json'")
+	}
+	if f := r.Flags().Lookup("output"); f == nil || f.DefValue != "text" {
+		t.Errorf("expected root persistent flag with default 'text'")
+	}
+}
+
+// 测试缺少必填 flags 时在 Run 之前失败，并在错误中列出全部缺少的 flags
+func TestCommand_MarkFlagRequired(t *testing.T) {
+	ran := false
+	r := &Command{Use: "root"}
+	sub := &Command{
+		Use:          "login",
+		SilenceUsage: true,
+		Run:          func(cmd *Command, args []string) { ran = true },
+	}
+	r.AddCommand(sub)
+	r.PersistentFlags().String("token", "", "api token")
+	sub.LocalFlags().String("user", "", "user name")
+	sub.LocalFlags().String("password", "", "password")
+	for _, name := range []string{"user", "password", "token"} {
+		if err := sub.MarkFlagRequired(name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := sub.MarkFlagRequired("missing"); err == nil {
+		t.Errorf("expected error for unknown flag")
+	}
+
+	expected := "required flag(s) '--password', '--token' not set"
+	if err := r.ExecuteLine("login --user bob"); err == nil || err.Error() != expected || ran {
+		t.Errorf("expected '%s' without running, but got '%v'", expected, err)
+	}
+	if err := r.ExecuteLine("login --user bob --password x --token y"); err != nil || !ran {
+		t.Errorf("expected command to run without error, but got '%v'", err)
+	}
+}
+
+// 测试必须同时指定的 flags 只指定了一部分时执行失败，并列出缺少的 flags
+func TestCommand_MarkFlagsRequiredTogether(t *testing.T) {
+	c := &Command{Use: "login", SilenceUsage: true, Run: func(cmd *Command, args []string) {}}
+	c.LocalFlags().String("user", "", "user name")
+	c.LocalFlags().String("password", "", "password")
+	if err := c.MarkFlagsRequiredTogether("user", "password"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.MarkFlagsRequiredTogether("user", "missing"); err == nil {
+		t.Errorf("expected error for unknown flag")
+	}
+
+	expected := "flags '--user', '--password' must be set together, missing '--password'"
+	if err := c.ExecuteLine("--user bob"); err == nil || err.Error() != expected {
+		t.Errorf("expected '%s' but got '%v'", expected, err)
+	}
+	c.Reset()
+	if err := c.ExecuteLine(""); err != nil {
+		t.Errorf("expected no error without any flag in the group, but got '%v'", err)
+	}
+	if err := c.ExecuteLine("--user bob --password x"); err != nil {
+		t.Errorf("expected no error with all flags in the group, but got '%v'", err)
+	}
+}
+
+// 测试至少需要指定一个的 flags 都没有指定时执行失败
+func TestCommand_MarkFlagsOneRequired(t *testing.T) {
+	c := &Command{Use: "load", SilenceUsage: true, Run: func(cmd *Command, args []string) {}}
+	c.LocalFlags().String("file", "", "input file")
+	c.LocalFlags().String("url", "", "input url")
+	c.LocalFlags().Bool("stdin", false, "read from stdin")
+	if err := c.MarkFlagsOneRequired("file", "url", "stdin"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.MarkFlagsOneRequired("file", "missing"); err == nil {
+		t.Errorf("expected error for unknown flag")
+	}
+
+	expected := "at least one of the flags '--file', '--url', '--stdin' must be set"
+	if err := c.ExecuteLine(""); err == nil || err.Error() != expected {
+		t.Errorf("expected '%s' but got '%v'", expected, err)
+	}
+	if err := c.ExecuteLine("--url http://example.com"); err != nil {
+		t.Errorf("expected no error with one flag in the group, but got '%v'", err)
+	}
+}
+
+// 测试设置 FParseErrWhitelist.UnknownFlags 后忽略未知的 flags，并可以取得它们转交给其他程序
+func TestCommand_FParseErrWhitelist(t *testing.T) {
+	var verbose bool
+	var args, unknown []string
+	r := &Command{Use: "wrap"}
+	sub := &Command{
+		Use:                "build",
+		FParseErrWhitelist: FParseErrWhitelist{UnknownFlags: true},
+		Run: func(cmd *Command, a []string) {
+			verbose, _ = cmd.Flags().GetBool("verbose")
+			args, unknown = a, cmd.UnknownFlags()
+		},
+	}
+	sub.LocalFlags().BoolP("verbose", "v", false, "verbose output")
+	sub.LocalFlags().String("tag", "", "image tag")
+	r.AddCommand(sub)
+
+	if err := r.ExecuteLine("build --tag v1 --cache-from registry -v --no-cache -j=4 ./src"); err != nil {
+		t.Fatalf("expected unknown flags to be ignored, but got '%v'", err)
+	}
+	if !verbose {
+		t.Errorf("expected known flag '--verbose' to be parsed")
+	}
+	if !reflect.DeepEqual(args, []string{"./src"}) {
+		t.Errorf("expected args [./src] but got %v", args)
+	}
+	if expected := []string{"--cache-from", "registry", "--no-cache", "-j=4"}; !reflect.DeepEqual(unknown, expected) {
+		t.Errorf("expected unknown flags %v but got %v", expected, unknown)
+	}
+
+	sub.FParseErrWhitelist.UnknownFlags = false
+	r.Reset()
+	if err := r.ExecuteLine("build --no-cache"); err == nil {
+		t.Errorf("expected error for unknown flag without whitelist")
+	}
+}
+
+// 测试 SetFlagDefaultFunc 设置的默认值只在没有指定 flag 时计算一次，并且可以通过绑定的变量读取
+func TestCommand_SetFlagDefaultFunc(t *testing.T) {
+	calls := 0
+	var kubeconfig string
+	c := &Command{Use: "get"}
+	p := c.LocalFlags().String("kubeconfig", "", "path to the kubeconfig file")
+	c.Run = func(cmd *Command, args []string) {
+		kubeconfig = *p
+	}
+	c.LocalFlags().Bool("watch", false, "watch for changes")
+	if err := c.SetFlagDefaultFunc("kubeconfig", func() string {
+		calls++
+		return "/home/bob/.kube/config"
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.SetFlagDefaultFunc("missing", func() string { return "" }); err == nil {
+		t.Errorf("expected error for unknown flag")
+	}
+
+	if err := c.ExecuteLine("--watch --kubeconfig /tmp/config"); err != nil || kubeconfig != "/tmp/config" {
+		t.Errorf("expected '/tmp/config' but got '%s', error '%v'", kubeconfig, err)
+	}
+	if calls != 0 {
+		t.Errorf("expected default not to be computed when the flag is set, but it was computed %d time(s)", calls)
+	}
+
+	c.Reset()
+	if err := c.ExecuteLine("--watch"); err != nil || kubeconfig != "/home/bob/.kube/config" {
+		t.Errorf("expected computed default but got '%s', error '%v'", kubeconfig, err)
+	}
+	if v, _ := c.Flags().GetString("kubeconfig"); v != kubeconfig {
+		t.Errorf("expected GetString to return '%s' but got '%s'", kubeconfig, v)
+	}
+	var buf bytes.Buffer
+	if err := c.renderUsage(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), `(default "/home/bob/.kube/config")`) {
+		t.Errorf("expected computed default in usage but got:\n%s", buf.String())
+	}
+	if calls != 1 {
+		t.Errorf("expected default to be computed once but it was computed %d time(s)", calls)
+	}
+}
+
+// 测试 ValidateFlag 注册的函数在解析后校验 flags 的取值，并在同一个错误中列出全部不合法的取值
+func TestCommand_ValidateFlag(t *testing.T) {
+	r := &Command{Use: "app"}
+	r.PersistentFlags().String("host", "localhost", "server host")
+	sub := &Command{Use: "serve", SilenceUsage: true, Run: func(cmd *Command, args []string) {}}
+	sub.LocalFlags().Int("port", 80, "listen port")
+	sub.LocalFlags().String("name", "", "server name")
+	r.AddCommand(sub)
+
+	if err := sub.ValidateFlag("port", func(v string) error {
+		if port, _ := strconv.Atoi(v); port < 1 || port > 65535 {
+			return errors.New("must be between 1 and 65535")
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.ValidateFlag("host", func(v string) error {
+		if strings.Contains(v, " ") {
+			return errors.New("must not contain spaces")
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := sub.ValidateFlag("name", func(v string) error { return errors.New("always invalid") }); err != nil {
+		t.Fatal(err)
+	}
+	if err := sub.ValidateFlag("missing", func(v string) error { return nil }); err == nil {
+		t.Errorf("expected error for unknown flag")
+	}
+
+	if err := r.ExecuteLine("serve --port 8080"); err != nil {
+		t.Errorf("expected no error for valid values and unset flags, but got '%v'", err)
+	}
+	r.Reset()
+	expected := "invalid flag value(s):\n  '--host=my host': must not contain spaces\n  '--port=70000': must be between 1 and 65535"
+	err := r.ExecuteLine(`serve --port 70000 --host "my host"`)
+	if err == nil || err.Error() != expected {
+		t.Errorf("expected\n%s\nbut got\n%v", expected, err)
+	}
+}
@@ -0,0 +1,47 @@
+package bobra
+
+import (
+	"fmt"
+	"io"
+)
+
+// 将以 c 为根的命令树以 DOT 格式输出到 w，节点为命令，边为父子关系。
+// 隐藏的命令用虚线框表示，废弃的命令用灰色填充表示
+func (c *Command) GenGraphviz(w io.Writer) error {
+	if _, err := fmt.Fprintf(w, "digraph %q {\n  node [shape=box];\n", c.CommandPath()); err != nil {
+		return err
+	}
+	if err := c.genGraphvizNodes(w); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintln(w, "}")
+	return err
+}
+
+// 输出命令 c 及其子命令的节点和边
+func (c *Command) genGraphvizNodes(w io.Writer) error {
+	label := c.Name()
+	if c.Short != "" {
+		label += "\n" + c.Short
+	}
+	style := ""
+	switch {
+	case c.Deprecated != "":
+		style = ", style=filled, fillcolor=lightgrey"
+	case c.Hidden:
+		style = ", style=dashed"
+	}
+	if _, err := fmt.Fprintf(w, "  %q [label=%q%s];\n", c.CommandPath(), label, style); err != nil {
+		return err
+	}
+
+	for _, sub := range c.commands {
+		if _, err := fmt.Fprintf(w, "  %q -> %q;\n", c.CommandPath(), sub.CommandPath()); err != nil {
+			return err
+		}
+		if err := sub.genGraphvizNodes(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
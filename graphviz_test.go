@@ -0,0 +1,34 @@
+package bobra
+
+import (
+	"bytes"
+	"testing"
+)
+
+// 测试生成的 DOT 包含每个命令的节点和父子关系的边
+func TestCommand_GenGraphviz(t *testing.T) {
+	r := &Command{Use: "mycli", Short: "my cli"}
+	service := &Command{Use: "service", Short: "manage services"}
+	internal := &Command{Use: "internal", Hidden: true}
+	old := &Command{Use: "old", Deprecated: "use service instead"}
+	r.AddCommand(service, internal, old)
+
+	var buf bytes.Buffer
+	if err := r.GenGraphviz(&buf); err != nil {
+		t.Fatal(err)
+	}
+	expected := `digraph "mycli" {
+  node [shape=box];
+  "mycli" [label="mycli\nmy cli"];
+  "mycli" -> "mycli service";
+  "mycli service" [label="service\nmanage services"];
+  "mycli" -> "mycli internal";
+  "mycli internal" [label="internal", style=dashed];
+  "mycli" -> "mycli old";
+  "mycli old" [label="old", style=filled, fillcolor=lightgrey];
+}
+`
+	if buf.String() != expected {
+		t.Errorf("expected\n%s\nbut got\n%s", expected, buf.String())
+	}
+}
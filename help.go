@@ -0,0 +1,68 @@
+package bobra
+
+import (
+	"encoding/json"
+	"io"
+)
+
+// 输出 JSON 格式使用方法的参数名称
+const helpJSONFlagName = "help-json"
+
+// JSON 格式的命令使用方法
+type commandHelp struct {
+	Name        string        `json:"name"`
+	Path        string        `json:"path"`
+	Short       string        `json:"short"`
+	Long        string        `json:"long"`
+	Example     string        `json:"example"`
+	Flags       []FlagInfo    `json:"flags"`
+	Subcommands []commandHelp `json:"subcommands,omitempty"`
+}
+
+// 为根命令注册隐藏的全局参数 --help-json，指定该参数时输出命令的 JSON 格式使用方法而不执行命令
+func (c *Command) EnableHelpJSON() {
+	root := c.Root()
+	root.PersistentFlags().Bool(helpJSONFlagName, false, "print help as JSON")
+	root.PersistentFlags().MarkHidden(helpJSONFlagName)
+	root.helpJSONEnabled = true
+}
+
+// 判断本次执行是否指定了 --help-json
+func (c *Command) helpJSONRequested() bool {
+	if !c.Root().helpJSONEnabled {
+		return false
+	}
+	requested, err := c.Flags().GetBool(helpJSONFlagName)
+	return err == nil && requested
+}
+
+// 将命令的使用方法以 JSON 格式输出到 w
+func (c *Command) printHelpJSON(w io.Writer) error {
+	help := c.help()
+	for _, sub := range c.commands {
+		if sub.IsAvailable() {
+			help.Subcommands = append(help.Subcommands, sub.help())
+		}
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(help)
+}
+
+// 返回命令本身的使用方法，不包含子命令
+func (c *Command) help() commandHelp {
+	flags := []FlagInfo{}
+	for _, info := range c.FlagMetadata() {
+		if !info.Hidden {
+			flags = append(flags, info)
+		}
+	}
+	return commandHelp{
+		Name:    c.Name(),
+		Path:    c.CommandPath(),
+		Short:   c.Short,
+		Long:    c.Long,
+		Example: c.Example,
+		Flags:   flags,
+	}
+}
@@ -0,0 +1,229 @@
+package bobra
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+)
+
+// 捕获 f 执行过程中输出到标准输出的内容
+func captureStdout(t *testing.T, f func()) string {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+
+	f()
+	w.Close()
+	out, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(out)
+}
+
+// 测试 --help-json 输出子命令的 JSON 格式使用方法且不执行命令
+func TestCommand_EnableHelpJSON(t *testing.T) {
+	ran := false
+	r := &Command{Use: "root"}
+	sub := &Command{
+		Use:     "sub",
+		Short:   "a sub command",
+		Example: "root sub --name x",
+		Run: func(cmd *Command, args []string) {
+			ran = true
+		},
+	}
+	sub.LocalFlags().String("name", "", "object name")
+	r.AddCommand(sub)
+	r.EnableHelpJSON()
+
+	os.Args = []string{"root", "sub", "--help-json"}
+	out := captureStdout(t, func() {
+		if err := r.Execute(); err != nil {
+			t.Errorf("expected no error but got '%v'", err)
+		}
+	})
+	if ran {
+		t.Errorf("expected Run not to be called")
+	}
+
+	var help commandHelp
+	if err := json.Unmarshal([]byte(out), &help); err != nil {
+		t.Fatalf("expected valid JSON but got '%v':\n%s", err, out)
+	}
+	if help.Path != "root sub" || help.Short != sub.Short || help.Example != sub.Example {
+		t.Errorf("unexpected help '%+v'", help)
+	}
+	if len(help.Flags) != 1 || help.Flags[0].Name != "name" || help.Flags[0].Type != "string" {
+		t.Errorf("expected only flag 'name', but got '%+v'", help.Flags)
+	}
+}
+
+// 测试不同组合下渲染的使用方法没有多余的空行和行尾空白
+func TestCommand_UsageFormatting(t *testing.T) {
+	run := func(cmd *Command, args []string) {}
+	solo := &Command{Use: "solo", Long: "Solo does one thing.", Run: run}
+	group := &Command{Use: "group"}
+	group.AddCommand(&Command{Use: "one", Short: "first", Run: run}, &Command{Use: "two", Short: "second", Run: run})
+	app := &Command{Use: "app", Long: "App long.", Run: run, UsageFooter: "Docs: https://example.com"}
+	sub := &Command{Use: "sub", Short: "sub", Run: run}
+	app.AddCommand(sub)
+	app.GlobalFlags().BoolP("verbose", "v", false, "verbose output")
+	sub.LocalFlags().Int("port", 80, "listen port")
+	sub.LocalFlags().String("name", "", "")
+
+	cases := []struct {
+		cmd      *Command
+		expected string
+	}{
+		// 可执行，没有 flags 和子命令
+		{solo, `Solo does one thing.
+
+Usage:
+  solo
+`},
+		// 不可执行，有子命令，没有 flags 和介绍
+		{group, `Usage:
+  group [command]
+
+Available Commands:
+  one: first
+  two: second
+
+Use "group [command] --help" for more information about a command.
+`},
+		// 可执行，有子命令、全局 flags 和末尾说明
+		{app, `App long.
+
+Usage:
+  app [flags]
+  app [command]
+
+Available Commands:
+  sub: sub
+
+GlobalFlags (1):
+  -v, --verbose   verbose output
+
+Use "app [command] --help" for more information about a command.
+
+Docs: https://example.com
+`},
+		// 可执行，有局部和全局 flags，没有子命令
+		{sub, `Usage:
+  app sub [flags]
+
+LocalFlags (2):
+      --name string
+      --port int      listen port (default 80)
+
+GlobalFlags (1):
+  -v, --verbose   verbose output
+`},
+	}
+	for _, c := range cases {
+		var buf bytes.Buffer
+		if err := c.cmd.renderUsage(&buf); err != nil {
+			t.Fatal(err)
+		}
+		if buf.String() != c.expected {
+			t.Errorf("usage of '%s': expected\n%q\nbut got\n%q", c.cmd.Name(), c.expected, buf.String())
+		}
+	}
+}
+
+// 测试整理使用方法时去掉行尾空白、合并空行以及去掉首尾的空行
+func Test_CleanUsage(t *testing.T) {
+	input := "\n\n  Usage:  \n  cmd \n\n\n\nFlags:\t\n  -a\n\n \n"
+	expected := "  Usage:\n  cmd\n\nFlags:\n  -a\n"
+	if r := cleanUsage(input); r != expected {
+		t.Errorf("expected '%q' but got '%q'", expected, r)
+	}
+}
+
+// 测试设置了版本时根命令的使用方法显示版本，未设置时不显示
+func TestCommand_VersionInUsage(t *testing.T) {
+	r := &Command{Use: "mycli", Long: "My cli.", Run: func(cmd *Command, args []string) {}}
+
+	var buf bytes.Buffer
+	r.renderUsage(&buf)
+	if strings.Contains(buf.String(), "version") {
+		t.Errorf("expected no version line, but got:\n%s", buf.String())
+	}
+
+	r.Version = "1.2.3"
+	buf.Reset()
+	r.renderUsage(&buf)
+	if !strings.HasPrefix(buf.String(), "mycli version 1.2.3\n\nMy cli.\n") {
+		t.Errorf("expected version header, but got:\n%s", buf.String())
+	}
+}
+
+// 测试指定了 --dry-run 时使用方法提示当前处于演练模式
+func TestCommand_DryRunInUsage(t *testing.T) {
+	r := &Command{Use: "mycli", Long: "My cli.", Run: func(cmd *Command, args []string) {}}
+	r.EnableDryRunFlag()
+
+	var buf bytes.Buffer
+	r.renderUsage(&buf)
+	if strings.Contains(buf.String(), "Dry-run mode") {
+		t.Errorf("expected no dry-run note, but got:\n%s", buf.String())
+	}
+
+	if err := r.ParseFlags([]string{"--dry-run"}); err != nil {
+		t.Fatal(err)
+	}
+	buf.Reset()
+	r.renderUsage(&buf)
+	if !strings.HasPrefix(buf.String(), "Dry-run mode: no changes will be made.\n\nMy cli.\n") {
+		t.Errorf("expected dry-run note, but got:\n%s", buf.String())
+	}
+}
+
+// 测试分组的 flags 以各自的标题单独显示在 LocalFlags 之后，并且可以正常解析
+func TestCommand_FlagGroup(t *testing.T) {
+	var port int
+	c := &Command{
+		Use: "serve",
+		Run: func(cmd *Command, args []string) {
+			port, _ = cmd.Flags().GetInt("port")
+		},
+	}
+	c.LocalFlags().Bool("debug", false, "enable debugging")
+	c.FlagGroup("Networking").Int("port", 80, "listen port")
+	c.FlagGroup("Networking").String("host", "localhost", "listen host")
+	c.FlagGroup("Storage").String("data-dir", "/var/lib", "data directory")
+	c.FlagGroup("Empty")
+
+	var buf bytes.Buffer
+	if err := c.renderUsage(&buf); err != nil {
+		t.Fatal(err)
+	}
+	expected := `Usage:
+  serve [flags]
+
+LocalFlags (1):
+      --debug   enable debugging
+
+Networking (2):
+      --host string   listen host (default "localhost")
+      --port int      listen port (default 80)
+
+Storage (1):
+      --data-dir string   data directory (default "/var/lib")
+`
+	if buf.String() != expected {
+		t.Errorf("expected\n%q\nbut got\n%q", expected, buf.String())
+	}
+
+	if err := c.ExecuteLine("--port 8080"); err != nil || port != 8080 {
+		t.Errorf("expected grouped flag to be parsed as 8080, but got %d, error '%v'", port, err)
+	}
+}
@@ -0,0 +1,16 @@
+package bobra
+
+import "time"
+
+// 接收命令执行开始和结束事件的接口，用于接入统计、审计等系统
+type Instrumenter interface {
+	// 找到要执行的命令之后、解析 flags 之前调用
+	CommandStarted(cmd *Command)
+	// 命令执行结束之后调用，err 为执行返回的错误，duration 为执行耗时
+	CommandFinished(cmd *Command, err error, duration time.Duration)
+}
+
+// 为根命令设置 Instrumenter，之后的每次执行都会向它发送开始和结束事件
+func (c *Command) SetInstrumenter(i Instrumenter) {
+	c.instrumenter = i
+}
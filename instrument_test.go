@@ -0,0 +1,45 @@
+package bobra
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// 记录收到的事件的 Instrumenter
+type recordingInstrumenter struct {
+	events []string
+	errs   []error
+}
+
+func (r *recordingInstrumenter) CommandStarted(cmd *Command) {
+	r.events = append(r.events, "started:"+cmd.Name())
+}
+
+func (r *recordingInstrumenter) CommandFinished(cmd *Command, err error, duration time.Duration) {
+	r.events = append(r.events, "finished:"+cmd.Name())
+	r.errs = append(r.errs, err)
+}
+
+// 测试每次执行都会向 Instrumenter 发送开始和结束事件，结束事件携带执行返回的错误
+func TestCommand_SetInstrumenter(t *testing.T) {
+	failed := errors.New("failed")
+	r := &Command{Use: "root"}
+	ok := &Command{Use: "ok", Run: func(cmd *Command, args []string) {}}
+	fail := &Command{Use: "fail", RunE: func(cmd *Command, args []string) error { return failed }}
+	r.AddCommand(ok)
+	r.AddCommand(fail)
+	instrumenter := &recordingInstrumenter{}
+	r.SetInstrumenter(instrumenter)
+
+	r.ExecuteLine("ok")
+	r.ExecuteLine("fail")
+	expected := []string{"started:ok", "finished:ok", "started:fail", "finished:fail"}
+	if !reflect.DeepEqual(instrumenter.events, expected) {
+		t.Errorf("expected '%q' but got '%q'", expected, instrumenter.events)
+	}
+	if instrumenter.errs[0] != nil || instrumenter.errs[1] != failed {
+		t.Errorf("expected errors '[<nil> %v]' but got '%v'", failed, instrumenter.errs)
+	}
+}
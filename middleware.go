@@ -0,0 +1,33 @@
+package bobra
+
+// 命令的执行函数
+type HandlerFunc func(cmd *Command, args []string) error
+
+// 包装命令执行函数的中间件，用于为一组命令添加日志、统计、鉴权等通用逻辑
+type Middleware func(next HandlerFunc) HandlerFunc
+
+// 为该命令及其全部子命令注册中间件，先注册的中间件位于外层，祖先命令的中间件位于子命令的中间件外层
+func (c *Command) UseMiddleware(mw ...Middleware) {
+	c.middlewares = append(c.middlewares, mw...)
+}
+
+// 返回该命令的执行函数，设置了 RunE 时使用 RunE，否则使用 Run
+func (c *Command) runFunc() HandlerFunc {
+	if c.RunE != nil {
+		return c.RunE
+	}
+	return func(cmd *Command, args []string) error {
+		c.Run(cmd, args)
+		return nil
+	}
+}
+
+// 使用该命令及其祖先命令注册的中间件依次包装 run
+func (c *Command) wrapMiddlewares(run HandlerFunc) HandlerFunc {
+	for p := c; p != nil; p = p.parent {
+		for i := len(p.middlewares) - 1; i >= 0; i-- {
+			run = p.middlewares[i](run)
+		}
+	}
+	return run
+}
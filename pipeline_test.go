@@ -0,0 +1,486 @@
+package bobra
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// 测试 Resolve 阶段找到要执行的命令和它的 flags 参数，并记录本次执行的参数
+func TestCommand_Resolve(t *testing.T) {
+	r := &Command{Use: "root"}
+	sub := &Command{Use: "sub"}
+	r.AddCommand(sub)
+
+	args := []string{"root", "sub", "--name", "x"}
+	cmd, flags, err := r.Resolve(args)
+	if err != nil || cmd != sub || !reflect.DeepEqual(flags, args[2:]) || !reflect.DeepEqual(sub.InvocationArgs(), args) {
+		t.Errorf("expected 'sub' with flags '%q', but got '%s' with '%q', error '%v'", args[2:], cmd.Name(), flags, err)
+	}
+	if _, _, err := r.Resolve([]string{"root", "missing"}); !errors.Is(err, ErrUnknownCommand) {
+		t.Errorf("expected unknown command error but got '%v'", err)
+	}
+}
+
+// 测试 Validate 阶段解析 flags 并执行校验，且不会调用 Run
+func TestValidate(t *testing.T) {
+	ran := false
+	c := &Command{
+		Use:          "deploy",
+		SilenceUsage: true,
+		Run: func(cmd *Command, args []string) {
+			ran = true
+		},
+		PreRunValidate: func(cmd *Command, args []string) error {
+			if len(args) == 0 {
+				return errors.New("target required")
+			}
+			return nil
+		},
+	}
+	c.LocalFlags().Int("replicas", 1, "number of replicas")
+
+	if err := Validate(c, []string{"--replicas", "abc"}); !errors.Is(err, ErrFlagParse) {
+		t.Errorf("expected flag parse error but got '%v'", err)
+	}
+	if err := Validate(c, []string{"--replicas", "3"}); err == nil {
+		t.Errorf("expected validation error but got nil")
+	}
+	if err := Validate(c, []string{"--replicas", "3", "prod"}); err != nil {
+		t.Errorf("expected no error but got '%v'", err)
+	}
+	if r, _ := c.Flags().GetInt("replicas"); r != 3 || ran {
+		t.Errorf("expected replicas 3 without running, but got %d, ran '%v'", r, ran)
+	}
+}
+
+// 测试 Run 阶段调用命令的 Run 函数
+func TestRun(t *testing.T) {
+	var got []string
+	c := &Command{
+		Use: "echo",
+		Run: func(cmd *Command, args []string) {
+			got = args
+		},
+	}
+	if err := Run(c, []string{"a", "b"}); err != nil || !reflect.DeepEqual(got, []string{"a", "b"}) {
+		t.Errorf("expected Run to receive '[a b]', but got '%q', error '%v'", got, err)
+	}
+}
+
+// 测试 PreRun、Run、PostRun 的执行顺序，以及 RunE 失败时不执行 PostRun
+func TestRun_Hooks(t *testing.T) {
+	var calls []string
+	record := func(name string) func(cmd *Command, args []string) {
+		return func(cmd *Command, args []string) {
+			calls = append(calls, name)
+		}
+	}
+	c := &Command{
+		Use:     "hooks",
+		PreRun:  record("PreRun"),
+		Run:     record("Run"),
+		PostRun: record("PostRun"),
+	}
+	if err := Run(c, []string{}); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"PreRun", "Run", "PostRun"}
+	if !reflect.DeepEqual(calls, expected) {
+		t.Errorf("expected '%q' but got '%q'", expected, calls)
+	}
+
+	calls = nil
+	c.RunE = func(cmd *Command, args []string) error {
+		calls = append(calls, "RunE")
+		return errors.New("failed")
+	}
+	if err := Run(c, []string{}); err == nil {
+		t.Errorf("expected RunE error but got nil")
+	}
+	expected = []string{"PreRun", "RunE"}
+	if !reflect.DeepEqual(calls, expected) {
+		t.Errorf("expected '%q' but got '%q'", expected, calls)
+	}
+}
+
+// 测试子命令使用最近的祖先命令设置的 PersistentPreRun 和 PersistentPostRun
+func TestRun_PersistentHooks(t *testing.T) {
+	var calls []string
+	record := func(name string) func(cmd *Command, args []string) {
+		return func(cmd *Command, args []string) {
+			calls = append(calls, name+":"+cmd.Name())
+		}
+	}
+	r := &Command{
+		Use:               "root",
+		PersistentPreRun:  record("rootPersistentPreRun"),
+		PersistentPostRun: record("rootPersistentPostRun"),
+	}
+	service := &Command{Use: "service", PersistentPreRun: record("servicePersistentPreRun")}
+	start := &Command{Use: "start", PreRun: record("PreRun"), Run: record("Run"), PostRun: record("PostRun")}
+	r.AddCommand(service)
+	service.AddCommand(start)
+
+	if err := Run(start, []string{}); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"servicePersistentPreRun:start", "PreRun:start", "Run:start", "PostRun:start", "rootPersistentPostRun:start"}
+	if !reflect.DeepEqual(calls, expected) {
+		t.Errorf("expected '%q' but got '%q'", expected, calls)
+	}
+}
+
+// 测试 *E 钩子返回错误时终止执行，并由 Execute 返回该错误
+func TestRun_ErrorHooks(t *testing.T) {
+	var calls []string
+	hookErr := errors.New("unauthorized")
+	r := &Command{
+		Use: "root",
+		PersistentPreRunE: func(cmd *Command, args []string) error {
+			calls = append(calls, "PersistentPreRunE")
+			return hookErr
+		},
+	}
+	c := &Command{
+		Use:     "deploy",
+		PreRunE: func(cmd *Command, args []string) error { calls = append(calls, "PreRunE"); return nil },
+		Run:     func(cmd *Command, args []string) { calls = append(calls, "Run") },
+	}
+	r.AddCommand(c)
+
+	if err := r.ExecuteLine("deploy"); err != hookErr {
+		t.Errorf("expected '%v' but got '%v'", hookErr, err)
+	}
+	expected := []string{"PersistentPreRunE"}
+	if !reflect.DeepEqual(calls, expected) {
+		t.Errorf("expected '%q' but got '%q'", expected, calls)
+	}
+
+	calls = nil
+	r.PersistentPreRunE = nil
+	c.PostRunE = func(cmd *Command, args []string) error {
+		calls = append(calls, "PostRunE")
+		return hookErr
+	}
+	r.PersistentPostRun = func(cmd *Command, args []string) { calls = append(calls, "PersistentPostRun") }
+	if err := r.ExecuteLine("deploy"); err != hookErr {
+		t.Errorf("expected '%v' but got '%v'", hookErr, err)
+	}
+	expected = []string{"PreRunE", "Run", "PostRunE"}
+	if !reflect.DeepEqual(calls, expected) {
+		t.Errorf("expected '%q' but got '%q'", expected, calls)
+	}
+}
+
+// 测试 OnInitialize 注册的函数在解析 flags 之前调用，OnFinalize 注册的函数在命令结束之后调用
+func TestOnInitialize(t *testing.T) {
+	defer func() { initializers, finalizers = nil, nil }()
+	var calls []string
+	c := &Command{
+		Use: "root",
+		Run: func(cmd *Command, args []string) {
+			verbose, _ := cmd.Flags().GetBool("verbose")
+			calls = append(calls, fmt.Sprint("Run:", verbose))
+		},
+	}
+	c.LocalFlags().Bool("verbose", false, "verbose")
+	OnInitialize(func() {
+		calls = append(calls, fmt.Sprint("init:", c.Flags().Lookup("verbose").Changed))
+	})
+	OnFinalize(func() { calls = append(calls, "finalize") })
+
+	if err := c.ExecuteLine("--verbose"); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"init:false", "Run:true", "finalize"}
+	if !reflect.DeepEqual(calls, expected) {
+		t.Errorf("expected '%q' but got '%q'", expected, calls)
+	}
+}
+
+// 测试祖先命令注册的中间件按照顺序包装子命令的执行函数
+func TestCommand_UseMiddleware(t *testing.T) {
+	var calls []string
+	record := func(name string) Middleware {
+		return func(next HandlerFunc) HandlerFunc {
+			return func(cmd *Command, args []string) error {
+				calls = append(calls, name+":before")
+				err := next(cmd, args)
+				calls = append(calls, name+":after")
+				return err
+			}
+		}
+	}
+	r := &Command{Use: "root"}
+	sub := &Command{
+		Use: "sub",
+		Run: func(cmd *Command, args []string) { calls = append(calls, "Run") },
+	}
+	other := &Command{Use: "other", Run: func(cmd *Command, args []string) {}}
+	r.AddCommand(sub)
+	r.AddCommand(other)
+	r.UseMiddleware(record("logging"), record("metrics"))
+	sub.UseMiddleware(record("auth"))
+
+	if err := Run(sub, []string{}); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"logging:before", "metrics:before", "auth:before", "Run", "auth:after", "metrics:after", "logging:after"}
+	if !reflect.DeepEqual(calls, expected) {
+		t.Errorf("expected '%q' but got '%q'", expected, calls)
+	}
+
+	calls = nil
+	if err := Run(other, []string{}); err != nil {
+		t.Fatal(err)
+	}
+	expected = []string{"logging:before", "metrics:before", "metrics:after", "logging:after"}
+	if !reflect.DeepEqual(calls, expected) {
+		t.Errorf("expected '%q' but got '%q'", expected, calls)
+	}
+}
+
+// 测试 PreRunE 或 RunE 返回错误时 PostRunAlways 仍然被调用
+func TestRun_PostRunAlways(t *testing.T) {
+	var calls []string
+	failed := errors.New("failed")
+	c := &Command{
+		Use:           "root",
+		PreRunE:       func(cmd *Command, args []string) error { calls = append(calls, "PreRunE"); return failed },
+		Run:           func(cmd *Command, args []string) { calls = append(calls, "Run") },
+		PostRun:       func(cmd *Command, args []string) { calls = append(calls, "PostRun") },
+		PostRunAlways: func(cmd *Command, args []string) { calls = append(calls, "PostRunAlways") },
+	}
+	if err := Run(c, []string{}); err != failed {
+		t.Errorf("expected '%v' but got '%v'", failed, err)
+	}
+	expected := []string{"PreRunE", "PostRunAlways"}
+	if !reflect.DeepEqual(calls, expected) {
+		t.Errorf("expected '%q' but got '%q'", expected, calls)
+	}
+
+	calls = nil
+	c.PreRunE = nil
+	if err := Run(c, []string{}); err != nil {
+		t.Fatal(err)
+	}
+	expected = []string{"Run", "PostRun", "PostRunAlways"}
+	if !reflect.DeepEqual(calls, expected) {
+		t.Errorf("expected '%q' but got '%q'", expected, calls)
+	}
+}
+
+// 测试根命令设置 InheritAllHooks 时执行全部祖先命令的 persistent 钩子
+func TestRun_InheritAllHooks(t *testing.T) {
+	var calls []string
+	record := func(name string) func(cmd *Command, args []string) {
+		return func(cmd *Command, args []string) {
+			calls = append(calls, name)
+		}
+	}
+	r := &Command{
+		Use:               "root",
+		InheritHooks:      InheritAllHooks,
+		PersistentPreRun:  record("rootPre"),
+		PersistentPostRun: record("rootPost"),
+	}
+	service := &Command{
+		Use:               "service",
+		PersistentPreRunE: func(cmd *Command, args []string) error { calls = append(calls, "servicePre"); return nil },
+		PersistentPostRun: record("servicePost"),
+	}
+	start := &Command{Use: "start", Run: record("Run")}
+	r.AddCommand(service)
+	service.AddCommand(start)
+
+	if err := Run(start, []string{}); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"rootPre", "servicePre", "Run", "servicePost", "rootPost"}
+	if !reflect.DeepEqual(calls, expected) {
+		t.Errorf("expected '%q' but got '%q'", expected, calls)
+	}
+
+	calls = nil
+	r.InheritHooks = InheritNearestHook
+	if err := Run(start, []string{}); err != nil {
+		t.Fatal(err)
+	}
+	expected = []string{"servicePre", "Run", "servicePost"}
+	if !reflect.DeepEqual(calls, expected) {
+		t.Errorf("expected '%q' but got '%q'", expected, calls)
+	}
+}
+
+// 测试 Run 只收到去除 flags 后的位置参数，RawArgs 返回原始参数列表
+func TestCommand_RawArgs(t *testing.T) {
+	var got []string
+	r := &Command{Use: "root"}
+	sub := &Command{
+		Use: "copy",
+		Run: func(cmd *Command, args []string) { got = args },
+	}
+	sub.LocalFlags().Bool("force", false, "overwrite")
+	sub.LocalFlags().Int("retries", 0, "retries")
+	r.AddCommand(sub)
+
+	if err := r.ExecuteLine("copy --force a.txt --retries 3 b.txt"); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"a.txt", "b.txt"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected '%q' but got '%q'", expected, got)
+	}
+	expectedRaw := []string{"--force", "a.txt", "--retries", "3", "b.txt"}
+	if !reflect.DeepEqual(sub.RawArgs(), expectedRaw) {
+		t.Errorf("expected '%q' but got '%q'", expectedRaw, sub.RawArgs())
+	}
+}
+
+// 测试 "--" 之后的参数不被解析为 flags，原样交给 Run
+func TestCommand_ArgsLenAtDash(t *testing.T) {
+	var got []string
+	var atDash int
+	r := &Command{Use: "root"}
+	sub := &Command{
+		Use: "exec",
+		Run: func(cmd *Command, args []string) {
+			got = args
+			atDash = cmd.ArgsLenAtDash()
+		},
+	}
+	sub.LocalFlags().Bool("tty", false, "allocate a tty")
+	r.AddCommand(sub)
+
+	if err := r.ExecuteLine("exec --tty box -- ls --tty -l help"); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"box", "ls", "--tty", "-l", "help"}
+	if !reflect.DeepEqual(got, expected) || atDash != 1 {
+		t.Errorf("expected '%q', 1 but got '%q', %d", expected, got, atDash)
+	}
+
+	r.Reset()
+	if err := r.ExecuteLine("exec box"); err != nil {
+		t.Fatal(err)
+	}
+	if atDash != -1 {
+		t.Errorf("expected -1 without '--' but got %d", atDash)
+	}
+}
+
+// 测试设置 Passthrough 后剩余的参数原样交给 Run，未知的 flags 不会导致解析失败
+func TestCommand_Passthrough(t *testing.T) {
+	var got []string
+	var verbose bool
+	r := &Command{Use: "root"}
+	r.GlobalFlags().Bool("verbose", false, "verbose output")
+	sub := &Command{
+		Use:         "docker",
+		Passthrough: true,
+		Run: func(cmd *Command, args []string) {
+			got = args
+			verbose, _ = cmd.Flags().GetBool("verbose")
+		},
+	}
+	r.AddCommand(sub)
+
+	if err := r.ExecuteLine("docker run -it --rm --verbose alpine help"); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"run", "-it", "--rm", "--verbose", "alpine", "help"}
+	if !reflect.DeepEqual(got, expected) || !verbose {
+		t.Errorf("expected '%q', true but got '%q', %v", expected, got, verbose)
+	}
+}
+
+// 测试设置 DisableFlagParsing 后不解析 flags，全部参数原样交给 Run
+func TestCommand_DisableFlagParsing(t *testing.T) {
+	var got []string
+	r := &Command{Use: "root"}
+	sub := &Command{
+		Use:                "lua",
+		DisableFlagParsing: true,
+		Run:                func(cmd *Command, args []string) { got = args },
+	}
+	sub.LocalFlags().Bool("quiet", false, "quiet")
+	r.AddCommand(sub)
+
+	if err := r.ExecuteLine("lua --quiet -e 'print(1)' -- script.lua"); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"--quiet", "-e", "print(1)", "--", "script.lua"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected '%q' but got '%q'", expected, got)
+	}
+	if sub.Flags().Changed("quiet") {
+		t.Errorf("expected --quiet not to be parsed")
+	}
+}
+
+// 测试 SetInterspersed(false) 后第一个位置参数之后的 flags 原样作为位置参数
+func TestCommand_SetInterspersed(t *testing.T) {
+	var got []string
+	var rm bool
+	r := &Command{Use: "root"}
+	sub := &Command{
+		Use: "run",
+		Run: func(cmd *Command, args []string) {
+			got = args
+			rm, _ = cmd.Flags().GetBool("rm")
+		},
+	}
+	sub.LocalFlags().Bool("rm", false, "remove after exit")
+	r.AddCommand(sub)
+
+	if err := r.ExecuteLine("run --rm alpine ls --rm"); err != nil {
+		t.Fatal(err)
+	}
+	if expected := []string{"alpine", "ls"}; !reflect.DeepEqual(got, expected) || !rm {
+		t.Errorf("expected '%q', true but got '%q', %v", expected, got, rm)
+	}
+
+	r.Reset()
+	sub.SetInterspersed(false)
+	if err := r.ExecuteLine("run alpine ls --rm"); err != nil {
+		t.Fatal(err)
+	}
+	if expected := []string{"alpine", "ls", "--rm"}; !reflect.DeepEqual(got, expected) || rm {
+		t.Errorf("expected '%q', false but got '%q', %v", expected, got, rm)
+	}
+}
+
+// 测试 PreRunE 或 PersistentPreRunE 返回 ErrSkipRun 时不执行命令和后续的钩子，Execute 返回 nil
+func TestRun_ErrSkipRun(t *testing.T) {
+	for _, persistent := range []bool{false, true} {
+		calls := []string{}
+		skip := func(cmd *Command, args []string) error {
+			calls = append(calls, "pre")
+			return fmt.Errorf("nothing to do: %w", ErrSkipRun)
+		}
+		r := &Command{
+			Use:               "root",
+			PersistentPostRun: func(cmd *Command, args []string) { calls = append(calls, "persistent-post") },
+		}
+		sub := &Command{
+			Use:     "sync",
+			Run:     func(cmd *Command, args []string) { calls = append(calls, "run") },
+			PostRun: func(cmd *Command, args []string) { calls = append(calls, "post") },
+		}
+		if persistent {
+			r.PersistentPreRunE = skip
+		} else {
+			sub.PreRunE = skip
+		}
+		r.AddCommand(sub)
+
+		if err := r.ExecuteLine("sync"); err != nil {
+			t.Errorf("expected nil error but got '%v'", err)
+		}
+		if !reflect.DeepEqual(calls, []string{"pre"}) {
+			t.Errorf("expected only the pre-run to be called, but got %q", calls)
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package bobra
+
+// 注册的插件
+type plugin struct {
+	name  string
+	build func() *Command
+}
+
+// 全局的插件注册表，按照注册顺序保存
+var plugins []plugin
+
+// 注册一个插件，build 用于构造插件对应的命令，通常在插件包的 init 函数中调用
+func RegisterPlugin(name string, build func() *Command) {
+	for _, p := range plugins {
+		if p.name == name {
+			panic("Plugin " + name + " is already registered")
+		}
+	}
+	plugins = append(plugins, plugin{name: name, build: build})
+}
+
+// 将所有注册的插件命令添加为 c 的子命令，如果与已有的子命令重名则返回错误且不添加任何插件
+func (c *Command) LoadPlugins() error {
+	cmds := []*Command{}
+	names := map[string]bool{}
+	for _, p := range plugins {
+		cmd := p.build()
+		if names[cmd.Name()] || c.findSubCmd(cmd.Name()) != nil {
+			return ObjectExists{Type: "Command", Name: cmd.Name()}
+		}
+		names[cmd.Name()] = true
+		cmds = append(cmds, cmd)
+	}
+	c.AddCommand(cmds...)
+	return nil
+}
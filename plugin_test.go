@@ -0,0 +1,32 @@
+package bobra
+
+import (
+	"testing"
+)
+
+// 测试加载注册的插件后能够作为子命令被找到
+func TestCommand_LoadPlugins(t *testing.T) {
+	defer func() { plugins = nil }()
+	RegisterPlugin("hello", func() *Command {
+		return &Command{Use: "hello", Run: func(cmd *Command, args []string) {}}
+	})
+	RegisterPlugin("bye", func() *Command {
+		return &Command{Use: "bye", Run: func(cmd *Command, args []string) {}}
+	})
+
+	r := &Command{Use: "root"}
+	if err := r.LoadPlugins(); err != nil {
+		t.Fatalf("expected no error but got '%v'", err)
+	}
+	for _, name := range []string{"hello", "bye"} {
+		cmd, _, err := r.Find([]string{"root", name})
+		if err != nil || cmd.Name() != name {
+			t.Errorf("expected to find '%s' but got '%s', error '%v'", name, cmd.Name(), err)
+		}
+	}
+
+	// 再次加载时与已有的子命令重名
+	if err := r.LoadPlugins(); err != (ObjectExists{Type: "Command", Name: "hello"}) {
+		t.Errorf("expected name collision error but got '%v'", err)
+	}
+}
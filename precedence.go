@@ -0,0 +1,96 @@
+package bobra
+
+import (
+	"fmt"
+	"strings"
+
+	flag "github.com/spf13/pflag"
+)
+
+// flag 取值来源的默认优先级，由高到低排列
+var defaultValuePrecedence = []FlagSource{FlagSourceFlag, FlagSourceEnv, FlagSourceConfig, FlagSourceDefault}
+
+// 为整棵命令树设置 flag 取值来源的优先级，order 由高到低排列，必须恰好包含 "flag"、"env"、"config" 和 "default" 各一次，
+// 如 []string{"flag", "config", "env", "default"} 让配置优先于环境变量。默认为 flag > env > config > default
+func (c *Command) SetValuePrecedence(order []string) error {
+	invalid := fmt.Errorf("invalid value precedence [%s]: must contain each of flag, env, config and default exactly once", strings.Join(order, ", "))
+	precedence := []FlagSource{}
+	for _, name := range order {
+		if !stringInSlice(name, sourceNames(defaultValuePrecedence)) || stringInSlice(name, sourceNames(precedence)) {
+			return invalid
+		}
+		precedence = append(precedence, FlagSource(name))
+	}
+	if len(precedence) != len(defaultValuePrecedence) {
+		return invalid
+	}
+	c.Root().valuePrecedence = precedence
+	return nil
+}
+
+// 返回取值来源的名称
+func sourceNames(sources []FlagSource) []string {
+	names := []string{}
+	for _, source := range sources {
+		names = append(names, string(source))
+	}
+	return names
+}
+
+// 返回 flag 取值来源的优先级，由高到低排列
+func (c *Command) sourcePrecedence() []FlagSource {
+	if precedence := c.Root().valuePrecedence; precedence != nil {
+		return precedence
+	}
+	return defaultValuePrecedence
+}
+
+// 按照取值来源的优先级为每个 flag 选出取值，并记录取值的来源。每个 flag 只设置一次，
+// 切片类型的 flag 不会把不同来源的取值拼接在一起
+func (c *Command) applyFlagSources(config map[string]string) error {
+	precedence := c.sourcePrecedence()
+	var err error
+	c.Flags().VisitAll(func(f *flag.Flag) {
+		if err != nil {
+			return
+		}
+		for _, source := range precedence {
+			switch source {
+			case FlagSourceFlag:
+				if !f.Changed {
+					continue
+				}
+			case FlagSourceEnv:
+				env, v, ok := c.envFlagValue(f)
+				if !ok {
+					continue
+				}
+				if e := setFlagValue(f, v); e != nil {
+					err = fmt.Errorf("invalid value '%s' for flag '%s' from environment variable %s: %v", v, f.Name, env, e)
+					return
+				}
+			case FlagSourceConfig:
+				v, ok := config[f.Name]
+				if !ok {
+					continue
+				}
+				if e := setFlagValue(f, v); e != nil {
+					err = fmt.Errorf("invalid value '%s' for flag '%s' in config: %v", v, f.Name, e)
+					return
+				}
+			case FlagSourceDefault:
+				// 命令行中指定的值优先级更低时，恢复为默认值并清除被指定的标记
+				if f.Changed {
+					if e := setFlagValue(f, strings.TrimSuffix(strings.TrimPrefix(f.DefValue, "["), "]")); e != nil {
+						err = e
+						return
+					}
+					f.Changed = false
+				}
+			}
+			c.flagSources[f.Name] = source
+			return
+		}
+	})
+	return err
+}
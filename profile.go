@@ -0,0 +1,71 @@
+package bobra
+
+import (
+	"os"
+	"runtime"
+	"runtime/pprof"
+	"runtime/trace"
+)
+
+// 性能分析参数的名称
+const (
+	cpuProfileFlagName = "cpuprofile"
+	memProfileFlagName = "memprofile"
+	traceFlagName      = "trace"
+)
+
+// 为 root 注册隐藏的全局 --cpuprofile、--memprofile 和 --trace 参数，
+// 指定时在命令执行前后开始和结束对应的性能分析，并将结果写入参数指定的文件
+func EnableProfiling(root *Command) {
+	flags := root.PersistentFlags()
+	flags.String(cpuProfileFlagName, "", "write cpu profile to file")
+	flags.String(memProfileFlagName, "", "write memory profile to file")
+	flags.String(traceFlagName, "", "write execution trace to file")
+	for _, name := range []string{cpuProfileFlagName, memProfileFlagName, traceFlagName} {
+		flags.MarkHidden(name)
+	}
+	root.UseMiddleware(profiling)
+}
+
+// 根据性能分析参数在 next 前后开始和结束性能分析的中间件
+func profiling(next HandlerFunc) HandlerFunc {
+	return func(cmd *Command, args []string) error {
+		if path, _ := cmd.Flags().GetString(cpuProfileFlagName); path != "" {
+			f, err := os.Create(path)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			if err := pprof.StartCPUProfile(f); err != nil {
+				return err
+			}
+			defer pprof.StopCPUProfile()
+		}
+		if path, _ := cmd.Flags().GetString(traceFlagName); path != "" {
+			f, err := os.Create(path)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			if err := trace.Start(f); err != nil {
+				return err
+			}
+			defer trace.Stop()
+		}
+
+		if err := next(cmd, args); err != nil {
+			return err
+		}
+
+		if path, _ := cmd.Flags().GetString(memProfileFlagName); path != "" {
+			f, err := os.Create(path)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			runtime.GC()
+			return pprof.WriteHeapProfile(f)
+		}
+		return nil
+	}
+}
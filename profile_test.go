@@ -0,0 +1,35 @@
+package bobra
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// 测试指定性能分析参数时在执行后生成对应的文件，且参数不显示在使用方法中
+func TestEnableProfiling(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bobra")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	r := &Command{Use: "root"}
+	sub := &Command{Use: "sub", Run: func(cmd *Command, args []string) {}}
+	r.AddCommand(sub)
+	EnableProfiling(r)
+	if sub.HasAvailableGlobalFlags() {
+		t.Errorf("expected profiling flags to be hidden")
+	}
+
+	cpu, mem, trace := filepath.Join(dir, "cpu.out"), filepath.Join(dir, "mem.out"), filepath.Join(dir, "trace.out")
+	r.SetArgs([]string{"sub", "--cpuprofile", cpu, "--memprofile", mem, "--trace", trace})
+	if err := r.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{cpu, mem, trace} {
+		if info, err := os.Stat(path); err != nil || info.Size() == 0 {
+			t.Errorf("expected non-empty profile '%s', error '%v'", path, err)
+		}
+	}
+}
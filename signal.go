@@ -0,0 +1,55 @@
+package bobra
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// 为命令的上下文安装 SIGINT 和 SIGTERM 的处理函数，收到信号时调用 onInterrupt 并取消上下文。
+// 返回的函数用于卸载处理函数并恢复命令原来的上下文
+func (c *Command) notifySignals(onInterrupt func(sig os.Signal)) func() {
+	parent := c.ctx
+	ctx, cancel := context.WithCancel(c.Context())
+	c.ctx = ctx
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	done := make(chan struct{})
+	go func() {
+		select {
+		case sig := <-sigs:
+			if onInterrupt != nil {
+				onInterrupt(sig)
+			}
+			cancel()
+		case <-done:
+		}
+	}()
+
+	return func() {
+		signal.Stop(sigs)
+		close(done)
+		cancel()
+		c.ctx = parent
+	}
+}
+
+// 注册清理函数，命令的上下文因为收到信号或被取消而结束时，会在 Execute 返回之前按照注册的相反顺序调用。
+// 通常在 Run 中调用，注册的函数只对本次执行有效
+func (c *Command) OnShutdown(fn func()) {
+	c.shutdownHooks = append(c.shutdownHooks, fn)
+}
+
+// 命令执行结束后，如果上下文已经结束则调用注册的清理函数，并清空清理函数
+func (c *Command) shutdown() {
+	hooks := c.shutdownHooks
+	c.shutdownHooks = nil
+	if c.Context().Err() == nil {
+		return
+	}
+	for i := len(hooks) - 1; i >= 0; i-- {
+		hooks[i]()
+	}
+}
@@ -0,0 +1,70 @@
+package bobra
+
+import (
+	"context"
+	"os"
+	"reflect"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// 测试设置 HandleSignals 后收到 SIGINT 会调用清理函数并取消命令的上下文
+func TestCommand_HandleSignals(t *testing.T) {
+	var interrupted os.Signal
+	r := &Command{
+		Use:           "root",
+		HandleSignals: true,
+		OnInterrupt:   func(sig os.Signal) { interrupted = sig },
+	}
+	sub := &Command{
+		Use: "serve",
+		RunE: func(cmd *Command, args []string) error {
+			syscall.Kill(os.Getpid(), syscall.SIGINT)
+			select {
+			case <-cmd.Context().Done():
+				return cmd.Context().Err()
+			case <-time.After(5 * time.Second):
+				return nil
+			}
+		},
+	}
+	r.AddCommand(sub)
+
+	if err := r.ExecuteLine("serve"); err == nil {
+		t.Errorf("expected context to be canceled but got nil")
+	}
+	if interrupted != os.Interrupt {
+		t.Errorf("expected '%v' but got '%v'", os.Interrupt, interrupted)
+	}
+	if sub.Context().Err() != nil {
+		t.Errorf("expected context to be restored but got '%v'", sub.Context().Err())
+	}
+}
+
+// 测试上下文被取消时按照注册的相反顺序调用 OnShutdown 注册的清理函数，正常结束时不调用
+func TestCommand_OnShutdown(t *testing.T) {
+	var calls []string
+	r := &Command{
+		Use: "root",
+		Run: func(cmd *Command, args []string) {
+			cmd.OnShutdown(func() { calls = append(calls, "close listener") })
+			cmd.OnShutdown(func() { calls = append(calls, "flush logs") })
+		},
+	}
+	r.SetArgs([]string{})
+
+	if err := r.Execute(); err != nil || calls != nil {
+		t.Errorf("expected no cleanup without cancel, but got '%q', error '%v'", calls, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := r.ExecuteContext(ctx); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"flush logs", "close listener"}
+	if !reflect.DeepEqual(calls, expected) {
+		t.Errorf("expected '%q' but got '%q'", expected, calls)
+	}
+}
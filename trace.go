@@ -0,0 +1,57 @@
+package bobra
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	flag "github.com/spf13/pflag"
+)
+
+// 开启执行追踪的环境变量，值为 1 时输出追踪信息
+const traceEnv = "BOBRA_DEBUG"
+
+// 追踪信息的输出，测试时可以替换
+var traceOutput io.Writer = os.Stderr
+
+// 判断是否开启了执行追踪
+func traceEnabled() bool {
+	return os.Getenv(traceEnv) == "1"
+}
+
+// 开启了执行追踪时，向 traceOutput 输出一行追踪信息
+func tracef(format string, a ...interface{}) {
+	if !traceEnabled() {
+		return
+	}
+	fmt.Fprintf(traceOutput, "[bobra] "+format+"\n", a...)
+}
+
+// 输出命令解析后每个 flag 的取值以及是否在命令行中指定
+func (c *Command) traceFlags() {
+	if !traceEnabled() {
+		return
+	}
+	c.Flags().VisitAll(func(f *flag.Flag) {
+		source := "default"
+		if f.Changed {
+			source = "command line"
+		}
+		tracef("flag --%s=%s (%s) on '%s'", f.Name, f.Value.String(), source, c.CommandPath())
+	})
+}
+
+// 输出调用的钩子函数的名称以及它所属的命令
+func traceHook(name string, owner *Command) {
+	tracef("calling %s of '%s'", name, owner.CommandPath())
+}
+
+// 输出参数列表解析得到的命令
+func traceResolve(args []string, cmd *Command, err error) {
+	if err != nil {
+		tracef("resolving [%s] failed: %v", strings.Join(args, " "), err)
+		return
+	}
+	tracef("resolved [%s] to '%s'", strings.Join(args, " "), cmd.CommandPath())
+}
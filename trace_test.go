@@ -0,0 +1,45 @@
+package bobra
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+)
+
+// 测试设置 BOBRA_DEBUG=1 时输出命令解析、flags 解析和钩子调用的追踪信息
+func TestTrace(t *testing.T) {
+	var buf bytes.Buffer
+	traceOutput = &buf
+	defer func() { traceOutput = os.Stderr }()
+
+	r := &Command{Use: "root", PersistentPreRun: func(cmd *Command, args []string) {}}
+	sub := &Command{Use: "sub", Run: func(cmd *Command, args []string) {}}
+	sub.LocalFlags().Int("count", 1, "count")
+	sub.LocalFlags().String("name", "bob", "name")
+	r.AddCommand(sub)
+
+	if err := r.ExecuteLine("sub --count 3"); err != nil {
+		t.Fatal(err)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("expected no trace output without %s, but got:\n%s", traceEnv, buf.String())
+	}
+
+	os.Setenv(traceEnv, "1")
+	defer os.Unsetenv(traceEnv)
+	if err := r.ExecuteLine("sub --count 3"); err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{
+		"[bobra] resolved [root sub --count 3] to 'root sub'\n",
+		"[bobra] flag --count=3 (command line) on 'root sub'\n",
+		"[bobra] flag --name=bob (default) on 'root sub'\n",
+		"[bobra] calling PersistentPreRun of 'root'\n",
+		"[bobra] calling Run of 'root sub'\n",
+	} {
+		if !strings.Contains(buf.String(), expected) {
+			t.Errorf("expected trace output to contain %q, but got:\n%s", expected, buf.String())
+		}
+	}
+}
@@ -1,14 +1,26 @@
 package bobra
 
 import (
+	"fmt"
 	"io"
+	"os"
+	"regexp"
 	"strings"
 	"text/template"
+	"unicode"
 	flag "github.com/spf13/pflag"
 )
 var templateFuncs = template.FuncMap{
 	"trim":                    strings.TrimSpace,
+	"trimRight":               trimRightSpace,
 }
+
+// 命令摘要中命令路径的填充宽度
+const summaryPadding = 24
+
+// 匹配文本中的 URL，不包含结尾的标点
+var urlPattern = regexp.MustCompile(`https?://[^\s"'<>]*[^\s"'<>.,;:!?)]`)
+
 // 从 args 中解析出子命令的列表 ------ copy from github.com/spf13/cobra
 func stripFlags(args []string, c *Command) []string {
 	if len(args) == 0 {
@@ -68,6 +80,17 @@ func shortHasNoOptDefVal(name string, fs *flag.FlagSet) bool {
 	return flag.NoOptDefVal != ""
 }
 
+// 统计 fs 中未隐藏的 flags 的数量
+func countVisibleFlags(fs *flag.FlagSet) int {
+	count := 0
+	fs.VisitAll(func(f *flag.Flag) {
+		if !f.Hidden {
+			count++
+		}
+	})
+	return count
+}
+
 // 删除第一个匹配
 func removeFirstMatchStr(args []string, str string) []string {
 	for i, arg := range args {
@@ -81,6 +104,86 @@ func removeFirstMatchStr(args []string, str string) []string {
 	return args
 }
 
+// 删除全部匹配，返回新的切片
+func removeAllMatchStr(args []string, str string) []string {
+	ret := []string{}
+	for _, arg := range args {
+		if arg != str {
+			ret = append(ret, arg)
+		}
+	}
+	return ret
+}
+
+// 将程序名称之后第一个与 old 相同的参数替换为 new
+func replaceFirstMatchStr(args []string, old, new string) []string {
+	for i := 1; i < len(args); i++ {
+		if args[i] == old {
+			ret := append([]string{}, args...)
+			ret[i] = new
+			return ret
+		}
+	}
+	return args
+}
+
+// 判断 str 是否在 slice 中
+func stringInSlice(str string, slice []string) bool {
+	for _, s := range slice {
+		if s == str {
+			return true
+		}
+	}
+	return false
+}
+
+// 计算 a 和 b 之间的编辑距离
+func levenshtein(a, b string) int {
+	s, t := []rune(a), []rune(b)
+	prev := make([]int, len(t)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(s); i++ {
+		cur := make([]int, len(t)+1)
+		cur[0] = i
+		for j := 1; j <= len(t); j++ {
+			cost := 1
+			if s[i-1] == t[j-1] {
+				cost = 0
+			}
+			cur[j] = prev[j-1] + cost
+			if prev[j]+1 < cur[j] {
+				cur[j] = prev[j] + 1
+			}
+			if cur[j-1]+1 < cur[j] {
+				cur[j] = cur[j-1] + 1
+			}
+		}
+		prev = cur
+	}
+	return prev[len(t)]
+}
+
+// 删除 s 末尾的空白字符
+func trimRightSpace(s string) string {
+	return strings.TrimRightFunc(s, unicode.IsSpace)
+}
+
+// 整理渲染后的使用方法：删除每行末尾的空白，将连续的空行合并为一行，并去掉开头和结尾的空行
+func cleanUsage(s string) string {
+	lines := strings.Split(s, "\n")
+	cleaned := []string{}
+	for _, line := range lines {
+		line = trimRightSpace(line)
+		if line == "" && (len(cleaned) == 0 || cleaned[len(cleaned)-1] == "") {
+			continue
+		}
+		cleaned = append(cleaned, line)
+	}
+	return strings.TrimRight(strings.Join(cleaned, "\n"), "\n") + "\n"
+}
+
 // 将命令的的使用方式加载到模版中
 func templify(w io.Writer, text string, data interface{}) error {
 	t := template.New("usage")
@@ -88,3 +191,76 @@ func templify(w io.Writer, text string, data interface{}) error {
 	template.Must(t.Parse(text))
 	return t.Execute(w, data)
 }
+
+// 按照 shell 的规则将一行输入切分为参数列表，支持单引号、双引号以及反斜杠转义
+func splitArgs(line string) ([]string, error) {
+	args := []string{}
+	runes := []rune(line)
+	var cur strings.Builder
+	inArg := false
+	var quote rune
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case r == '\\' && quote != '\'':
+			if i+1 >= len(runes) {
+				return nil, fmt.Errorf("unexpected end of line after '\\' in: %s", line)
+			}
+			// 双引号中只有 '"' 和 '\\' 需要转义，其余的反斜杠原样保留
+			if next := runes[i+1]; quote == '"' && next != '"' && next != '\\' {
+				cur.WriteRune(r)
+			} else {
+				i++
+				cur.WriteRune(next)
+			}
+			inArg = true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				cur.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote = r
+			inArg = true
+		case unicode.IsSpace(r):
+			if inArg {
+				args = append(args, cur.String())
+				cur.Reset()
+				inArg = false
+			}
+		default:
+			cur.WriteRune(r)
+			inArg = true
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated %c quote in: %s", quote, line)
+	}
+	if inArg {
+		args = append(args, cur.String())
+	}
+	return args, nil
+}
+
+// 判断 w 是否为终端，测试时可以替换
+var isTerminal = func(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok || os.Getenv("TERM") == "dumb" {
+		return false
+	}
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// 将文本中的 URL 转换为 OSC 8 终端超链接
+func hyperlink(text string) string {
+	return urlPattern.ReplaceAllStringFunc(text, func(url string) string {
+		return "\x1b]8;;" + url + "\x1b\\" + url + "\x1b]8;;\x1b\\"
+	})
+}
+
+// 在 s 右侧填充空格直到长度为 padding
+func rpad(s string, padding int) string {
+	return fmt.Sprintf("%-*s", padding, s)
+}
@@ -34,3 +34,17 @@ func Test_RemoveFirstMatchStr(t *testing.T) {
 		t.Errorf("expected '%q' but got '%q'", expected, r)
 	}
 }
+
+// 测试按照 shell 规则切分一行输入
+func Test_SplitArgs(t *testing.T) {
+	input := `start --name "my \"big\" service" 'a b\c' x\ y "c:\dir"`
+	r, err := splitArgs(input)
+	expected := []string{"start", "--name", `my "big" service`, `a b\c`, "x y", `c:\dir`}
+	if err != nil || !reflect.DeepEqual(r, expected) {
+		t.Errorf("expected '%q' but got '%q', error '%v'", expected, r, err)
+	}
+
+	if _, err := splitArgs(`start "unterminated`); err == nil {
+		t.Errorf("expected error for unterminated quote, but got nil")
+	}
+}